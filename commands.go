@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/onvif"
+	"github.com/cctv-agent/internal/socketio"
+	"github.com/cctv-agent/internal/updater"
+)
+
+// Command types accepted on the "command" event
+const (
+	commandTypeStream   = "stream"
+	commandTypePTZ      = "ptz"
+	commandTypeUpdate   = "update"
+	commandTypeDiscover = "discover"
+	commandTypeSnapshot = "snapshot"
+
+	commandTypeConfigUpdate = "config_update"
+	commandTypeConfigGet    = "config_get"
+)
+
+// eventEmitter sends events to the server. Command results are emitted
+// through it rather than the Socket.IO client directly so that tests can
+// capture them.
+type eventEmitter interface {
+	Emit(event string, data interface{}) error
+}
+
+// asyncCommands are the command types that may take seconds to minutes to
+// complete, and so run concurrently with other commands
+var asyncCommands = map[string]bool{
+	commandTypeUpdate:       true,
+	commandTypeDiscover:     true,
+	commandTypeSnapshot:     true,
+	commandTypeConfigUpdate: true,
+}
+
+// maxDiscoveryTimeout bounds the discovery time requested by the server
+const maxDiscoveryTimeout = 30 * time.Second
+
+// handleCommand decodes a command received from the server and queues it
+// for processing, so that slow commands never block the Socket.IO client
+func (app *Application) handleCommand(data json.RawMessage) error {
+	var cmd socketio.Command
+	if err := socketio.DecodeEventData(data, &cmd); err != nil {
+		return fmt.Errorf("failed to decode command: %w", err)
+	}
+
+	if cmd.Type == "" {
+		err := fmt.Errorf("command type is required")
+		app.sendCommandResult(cmd, 0, nil, err)
+		return err
+	}
+
+	select {
+	case app.commandChan <- cmd:
+		return nil
+	case <-app.ctx.Done():
+		return app.ctx.Err()
+	default:
+		err := fmt.Errorf("command queue full, dropping %s command", cmd.Type)
+		app.sendCommandResult(cmd, 0, nil, err)
+		return err
+	}
+}
+
+// runCommand executes a command and reports its outcome to the server
+func (app *Application) runCommand(cmd socketio.Command) {
+	app.logger.Info("Processing command", "id", cmd.ID, "type", cmd.Type, "camera_id", cmd.CameraID)
+
+	start := time.Now()
+	data, err := app.executeCommand(cmd)
+	duration := time.Since(start)
+
+	if err != nil {
+		app.logger.Error("Command failed",
+			"id", cmd.ID,
+			"type", cmd.Type,
+			"camera_id", cmd.CameraID,
+			"duration", duration,
+			"error", err)
+	} else {
+		app.logger.Info("Command completed",
+			"id", cmd.ID,
+			"type", cmd.Type,
+			"camera_id", cmd.CameraID,
+			"duration", duration)
+	}
+
+	app.sendCommandResult(cmd, duration, data, err)
+}
+
+// sendCommandResult emits a command_result event for a command
+func (app *Application) sendCommandResult(cmd socketio.Command, duration time.Duration, data interface{}, err error) {
+	result := socketio.CommandResult{
+		ID:        cmd.ID,
+		Type:      cmd.Type,
+		CameraID:  cmd.CameraID,
+		Status:    socketio.CommandStatusSuccess,
+		Duration:  duration,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		result.Status = socketio.CommandStatusError
+		result.Error = err.Error()
+	}
+
+	if err := app.results.Emit("command_result", result); err != nil {
+		app.logger.Error("Failed to send command result", "id", cmd.ID, "error", err)
+	}
+}
+
+// executeCommand routes a command to the component responsible for it and
+// returns the data to include in the command result
+func (app *Application) executeCommand(cmd socketio.Command) (interface{}, error) {
+	switch cmd.Type {
+	case commandTypeStream:
+		return nil, app.handleStreamCommand(cmd)
+	case commandTypePTZ:
+		return app.handlePTZCommand(cmd)
+	case commandTypeUpdate:
+		return nil, app.handleUpdateCommand(cmd)
+	case commandTypeDiscover:
+		return app.handleDiscoverCommand(cmd)
+	case commandTypeSnapshot:
+		return app.handleSnapshotCommand(cmd)
+	case commandTypeConfigUpdate:
+		return app.handleConfigUpdateCommand(cmd)
+	case commandTypeConfigGet:
+		return app.getConfig().Redacted(), nil
+	default:
+		return nil, fmt.Errorf("unknown command type: %s", cmd.Type)
+	}
+}
+
+// handleStreamCommand starts, stops or restarts a camera stream
+func (app *Application) handleStreamCommand(cmd socketio.Command) error {
+	if cmd.CameraID == "" {
+		return fmt.Errorf("camera_id is required for %s commands", cmd.Type)
+	}
+
+	var sc socketio.StreamCommand
+	if err := decodeCommandData(cmd, &sc); err != nil {
+		return err
+	}
+
+	switch sc.Action {
+	case "start":
+		camera, err := app.getConfig().GetCameraByID(cmd.CameraID)
+		if err != nil {
+			return err
+		}
+		cam := *camera
+		return app.streamManager.AddCamera(&cam)
+	case "stop":
+		return app.streamManager.RemoveCamera(cmd.CameraID)
+	case "restart":
+		return app.streamManager.RestartStream(cmd.CameraID)
+	default:
+		return fmt.Errorf("unknown stream action: %s", sc.Action)
+	}
+}
+
+// handlePTZCommand forwards a PTZ command to the ONVIF controller
+func (app *Application) handlePTZCommand(cmd socketio.Command) (interface{}, error) {
+	if cmd.CameraID == "" {
+		return nil, fmt.Errorf("camera_id is required for %s commands", cmd.Type)
+	}
+
+	var pc socketio.PTZCommand
+	if err := decodeCommandData(cmd, &pc); err != nil {
+		return nil, err
+	}
+
+	switch pc.Action {
+	case "move":
+		return nil, app.onvifCtrl.Move(cmd.CameraID, onvif.PTZMovement{
+			Pan:   float32(pc.Pan),
+			Tilt:  float32(pc.Tilt),
+			Zoom:  float32(pc.Zoom),
+			Speed: float32(pc.Speed),
+		})
+	case "stop":
+		return nil, app.onvifCtrl.Stop(cmd.CameraID)
+	case "goto_preset":
+		return nil, app.onvifCtrl.GoToPreset(cmd.CameraID, presetToken(pc))
+	case "set_preset":
+		name := pc.PresetName
+		if name == "" {
+			name = fmt.Sprintf("preset_%d", pc.Preset)
+		}
+		token, err := app.onvifCtrl.SetPreset(cmd.CameraID, name)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"preset_token": token}, nil
+	case "remove_preset":
+		return nil, app.onvifCtrl.RemovePreset(cmd.CameraID, presetToken(pc))
+	default:
+		return nil, fmt.Errorf("unknown PTZ action: %s", pc.Action)
+	}
+}
+
+// handleUpdateCommand performs an update pushed by the server
+func (app *Application) handleUpdateCommand(cmd socketio.Command) error {
+	var uc socketio.UpdateCommand
+	if err := decodeCommandData(cmd, &uc); err != nil {
+		return err
+	}
+
+	if uc.URL == "" {
+		return fmt.Errorf("update URL is required")
+	}
+
+	if !app.updateMu.TryLock() {
+		return fmt.Errorf("an update is already in progress")
+	}
+	defer app.updateMu.Unlock()
+
+	info := updater.UpdateInfo{
+		Version:     uc.Version,
+		DownloadURL: uc.URL,
+		Checksum:    uc.Checksum,
+		Force:       uc.Force,
+	}
+
+	available, err := app.updater.CheckForUpdate(info)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return fmt.Errorf("version %s is not newer than %s", uc.Version, app.updater.GetCurrentVersion())
+	}
+
+	return app.updater.PerformUpdate(info)
+}
+
+// handleDiscoverCommand probes the local network for ONVIF devices and
+// returns the candidates to the server
+func (app *Application) handleDiscoverCommand(cmd socketio.Command) (interface{}, error) {
+	var dc socketio.DiscoverCommand
+	if len(cmd.Data) > 0 {
+		if err := decodeCommandData(cmd, &dc); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := time.Duration(dc.Timeout) * time.Second
+	if timeout > maxDiscoveryTimeout {
+		timeout = maxDiscoveryTimeout
+	}
+
+	devices, err := onvif.Discover(app.ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	app.logger.Info("ONVIF discovery completed", "devices", len(devices))
+	return devices, nil
+}
+
+// decodeCommandData decodes the command specific payload into v
+func decodeCommandData(cmd socketio.Command, v interface{}) error {
+	if len(cmd.Data) == 0 {
+		return fmt.Errorf("%s command has no data", cmd.Type)
+	}
+	if err := json.Unmarshal(cmd.Data, v); err != nil {
+		return fmt.Errorf("invalid %s command data: %w", cmd.Type, err)
+	}
+	return nil
+}
+
+// presetToken returns the preset token of a PTZ command, falling back to
+// the numeric preset when no token is given
+func presetToken(pc socketio.PTZCommand) string {
+	if pc.PresetToken != "" {
+		return pc.PresetToken
+	}
+	return strconv.Itoa(pc.Preset)
+}
+
+// handleSnapshotCommand captures a JPEG image of a camera
+func (app *Application) handleSnapshotCommand(cmd socketio.Command) (interface{}, error) {
+	if cmd.CameraID == "" {
+		return nil, fmt.Errorf("camera_id is required for %s commands", cmd.Type)
+	}
+
+	snapshot, err := app.streamManager.Snapshot(cmd.CameraID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture snapshot: %w", err)
+	}
+
+	return socketio.SnapshotResult{
+		ContentType: "image/jpeg",
+		Source:      snapshot.Source,
+		Size:        len(snapshot.Image),
+		TakenAt:     snapshot.TakenAt,
+		Image:       snapshot.Image,
+	}, nil
+}
+
+// handleConfigUpdateCommand validates a configuration pushed by the server,
+// saves it and applies it live. Saving keeps the replaced configuration as a
+// backup, which a later update can roll back to.
+func (app *Application) handleConfigUpdateCommand(cmd socketio.Command) (interface{}, error) {
+	var uc socketio.ConfigUpdateCommand
+	if err := decodeCommandData(cmd, &uc); err != nil {
+		return nil, err
+	}
+
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	// The update is made to the file's own settings, so that defaults are
+	// not written out
+	var doc config.Document
+	var backup string
+	var err error
+	switch {
+	case uc.Rollback:
+		doc, backup, err = config.LatestBackupDocument(app.configPath)
+	case len(uc.Config) > 0:
+		doc, err = config.ReadDocument(app.configPath)
+		if err == nil {
+			doc, err = doc.Patch(uc.Config, app.getConfig())
+		}
+	default:
+		err = fmt.Errorf("config or rollback is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := doc.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// A rollback consumes the backup it restores, so that the next one goes
+	// further back
+	if uc.Rollback {
+		err = config.RestoreBackup(backup, app.configPath)
+	} else {
+		err = config.SaveDocument(doc, app.configPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "config_update command"
+	if uc.Rollback {
+		reason = "config_update rollback"
+	}
+	diff := app.applyConfig(cfg, reason)
+
+	return socketio.ConfigUpdateResult{
+		Changes:         diff.Changes(),
+		RestartRequired: diff.RestartRequired,
+	}, nil
+}
@@ -1,9 +1,16 @@
 package config
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -23,14 +30,17 @@ type UpdaterConfig struct {
 
 // Config represents the main configuration structure
 type Config struct {
-	Agent      AgentConfig      `json:"agent" mapstructure:"agent"`
-	Logger     LoggerConfig     `json:"logger" mapstructure:"logger"`
-	SocketIO   SocketIOConfig   `json:"socketio" mapstructure:"socketio"`
-	Cameras    []CameraConfig   `json:"cameras" mapstructure:"cameras"`
-	FFmpeg     FFmpegConfig     `json:"ffmpeg" mapstructure:"ffmpeg"`
-	RTMP       RTMPConfig       `json:"rtmp" mapstructure:"rtmp"`
-	Updater    UpdaterConfig    `json:"updater" mapstructure:"updater"`
-	Monitoring MonitoringConfig `json:"monitoring" mapstructure:"monitoring"`
+	Agent      AgentConfig             `json:"agent" mapstructure:"agent"`
+	Logger     LoggerConfig            `json:"logger" mapstructure:"logger"`
+	SocketIO   SocketIOConfig          `json:"socketio" mapstructure:"socketio"`
+	Cameras    []CameraConfig          `json:"cameras" mapstructure:"cameras"`
+	FFmpeg     FFmpegConfig            `json:"ffmpeg" mapstructure:"ffmpeg"`
+	Profiles   map[string]FFmpegConfig `json:"ffmpeg_profiles" mapstructure:"ffmpeg_profiles"` // Named overrides of the FFmpeg settings
+	RTMP       RTMPConfig              `json:"rtmp" mapstructure:"rtmp"`
+	Updater    UpdaterConfig           `json:"updater" mapstructure:"updater"`
+	Monitoring MonitoringConfig        `json:"monitoring" mapstructure:"monitoring"`
+	Recording  RecordingConfig         `json:"recording" mapstructure:"recording"`
+	HLS        HLSConfig               `json:"hls" mapstructure:"hls"`
 }
 
 // AgentConfig represents agent-specific configuration
@@ -59,17 +69,60 @@ type LoggerConfig struct {
 
 // CameraConfig represents camera configuration
 type CameraConfig struct {
-	ID         string        `json:"id" mapstructure:"id"`
-	Name       string        `json:"name" mapstructure:"name"`
-	RTSPUrl    string        `json:"rtsp_url" mapstructure:"rtsp_url"`
-	Username   string        `json:"username" mapstructure:"username"`
-	Password   string        `json:"password" mapstructure:"password"`
-	ONVIFPort  int           `json:"onvif_port" mapstructure:"onvif_port"`
-	StreamID   string        `json:"stream_id" mapstructure:"stream_id"`
-	Enabled    bool          `json:"enabled" mapstructure:"enabled"`
-	PTZEnabled bool          `json:"ptz_enabled" mapstructure:"ptz_enabled"`
-	RetryCount int           `json:"retry_count" mapstructure:"retry_count"`
-	RetryDelay time.Duration `json:"retry_delay" mapstructure:"retry_delay"`
+	ID              string         `json:"id" mapstructure:"id"`
+	Name            string         `json:"name" mapstructure:"name"`
+	RTSPUrl         string         `json:"rtsp_url" mapstructure:"rtsp_url"` // Optional when ONVIFHost is set
+	Username        string         `json:"username" mapstructure:"username"`
+	Password        string         `json:"password" mapstructure:"password"`
+	RTSPUrlFile     string         `json:"rtsp_url_file,omitempty" mapstructure:"rtsp_url_file"` // File holding the RTSP URL, overrides rtsp_url
+	PasswordFile    string         `json:"password_file,omitempty" mapstructure:"password_file"` // File holding the password, overrides password
+	ONVIFHost       string         `json:"onvif_host" mapstructure:"onvif_host"`                 // ONVIF device host, defaults to the RTSP URL host
+	ONVIFPort       int            `json:"onvif_port" mapstructure:"onvif_port"`                 // ONVIF service port, defaults to 80
+	ONVIFPath       string         `json:"onvif_path" mapstructure:"onvif_path"`                 // Device service path, defaults to /onvif/device_service
+	ONVIFScheme     string         `json:"onvif_scheme" mapstructure:"onvif_scheme"`             // http or https, defaults to http
+	ONVIFProfile    string         `json:"onvif_profile" mapstructure:"onvif_profile"`           // Preferred media profile token
+	StreamID        string         `json:"stream_id" mapstructure:"stream_id"`
+	Enabled         bool           `json:"enabled" mapstructure:"enabled"`
+	PTZEnabled      bool           `json:"ptz_enabled" mapstructure:"ptz_enabled"`
+	StreamMode      string         `json:"stream_mode" mapstructure:"stream_mode"`             // transcode or passthrough, defaults to transcode
+	FFmpegProfile   string         `json:"ffmpeg_profile" mapstructure:"ffmpeg_profile"`       // Named profile applied over the global FFmpeg settings
+	FFmpeg          *FFmpegConfig  `json:"ffmpeg,omitempty" mapstructure:"ffmpeg"`             // Inline overrides applied over the profile
+	Outputs         []OutputConfig `json:"outputs,omitempty" mapstructure:"outputs"`           // Defaults to the RTMP server with stream_id
+	RetryCount      int            `json:"retry_count" mapstructure:"retry_count"`             // Failures before retries pause for the cooldown, negative retries forever
+	RetryDelay      time.Duration  `json:"retry_delay" mapstructure:"retry_delay"`             // Delay after the first failure, doubled after each further one
+	RetryMaxDelay   time.Duration  `json:"retry_max_delay" mapstructure:"retry_max_delay"`     // Upper bound of the delay between retries
+	RetryCooldown   time.Duration  `json:"retry_cooldown" mapstructure:"retry_cooldown"`       // Delay between retries once retry_count failures occurred
+	Record          bool           `json:"record" mapstructure:"record"`                       // Record segments locally
+	RecordingMaxAge time.Duration  `json:"recording_max_age" mapstructure:"recording_max_age"` // Defaults to recording.max_age
+}
+
+// Stream modes
+const (
+	StreamModeTranscode   = "transcode"   // Always re-encode with the FFmpeg settings
+	StreamModePassthrough = "passthrough" // Copy H.264/AAC sources, re-encode anything else
+)
+
+// Retry policy defaults
+const (
+	DefaultRetryCount    = 3
+	DefaultRetryDelay    = 5 * time.Second
+	DefaultRetryMaxDelay = 2 * time.Minute
+	DefaultRetryCooldown = 5 * time.Minute
+)
+
+// ONVIF endpoint defaults
+const (
+	DefaultONVIFScheme = "http"
+	DefaultONVIFPort   = 80
+	DefaultONVIFPath   = "/onvif/device_service"
+)
+
+// ONVIFEndpoint represents the address of a camera's ONVIF device service
+type ONVIFEndpoint struct {
+	Scheme string
+	Host   string
+	Port   int
+	Path   string
 }
 
 // SocketIOConfig represents Socket.IO configuration
@@ -91,17 +144,18 @@ type RTMPConfig struct {
 
 // FFmpegConfig represents FFmpeg configuration
 type FFmpegConfig struct {
-	Preset       string `json:"preset" mapstructure:"preset"`
-	Tune         string `json:"tune" mapstructure:"tune"`
-	CRF          int    `json:"crf" mapstructure:"crf"`
-	MaxRate      string `json:"max_rate" mapstructure:"max_rate"`
-	BufSize      string `json:"buf_size" mapstructure:"buf_size"`
-	AudioBitrate string `json:"audio_bitrate" mapstructure:"audio_bitrate"`
-	AudioRate    int    `json:"audio_rate" mapstructure:"audio_rate"`
-	VideoCodec   string `json:"video_codec" mapstructure:"video_codec"`
-	AudioCodec   string `json:"audio_codec" mapstructure:"audio_codec"`
-	LogLevel     string `json:"log_level" mapstructure:"log_level"`
-	ExtraArgs    string `json:"extra_args" mapstructure:"extra_args"`
+	Preset       string        `json:"preset" mapstructure:"preset"`
+	Tune         string        `json:"tune" mapstructure:"tune"`
+	CRF          int           `json:"crf" mapstructure:"crf"`
+	MaxRate      string        `json:"max_rate" mapstructure:"max_rate"`
+	BufSize      string        `json:"buf_size" mapstructure:"buf_size"`
+	AudioBitrate string        `json:"audio_bitrate" mapstructure:"audio_bitrate"`
+	AudioRate    int           `json:"audio_rate" mapstructure:"audio_rate"`
+	VideoCodec   string        `json:"video_codec" mapstructure:"video_codec"`
+	AudioCodec   string        `json:"audio_codec" mapstructure:"audio_codec"`
+	LogLevel     string        `json:"log_level" mapstructure:"log_level"`
+	ExtraArgs    string        `json:"extra_args" mapstructure:"extra_args"`
+	StallTimeout time.Duration `json:"stall_timeout" mapstructure:"stall_timeout"` // Restart a stream when FFmpeg makes no progress for this long
 }
 
 // MonitoringConfig represents monitoring configuration
@@ -109,24 +163,250 @@ type MonitoringConfig struct {
 	HealthCheckInterval time.Duration `json:"health_check_interval" mapstructure:"health_check_interval"`
 	MetricsEnabled      bool          `json:"metrics_enabled" mapstructure:"metrics_enabled"`
 	MetricsPort         int           `json:"metrics_port" mapstructure:"metrics_port"`
+	HealthEnabled       bool          `json:"health_enabled" mapstructure:"health_enabled"`
+	MinFreeDiskMB       int64         `json:"min_free_disk_mb" mapstructure:"min_free_disk_mb"` // Free space below which the agent is not ready
 }
 
-// LoadConfig loads configuration from file
-func LoadConfig(path string) (*Config, error) {
-	viper.SetConfigFile(path)
-	viper.SetConfigType("json")
+// RecordingConfig represents local segmented recording configuration
+type RecordingConfig struct {
+	Dir               string        `json:"dir" mapstructure:"dir"`                               // Root directory, one subdirectory per camera
+	Format            string        `json:"format" mapstructure:"format"`                         // Segment container: mp4 or mkv
+	SegmentDuration   time.Duration `json:"segment_duration" mapstructure:"segment_duration"`     // Length of each segment
+	MaxAge            time.Duration `json:"max_age" mapstructure:"max_age"`                       // Delete segments older than this, 0 keeps them
+	MaxSizeMB         int64         `json:"max_size_mb" mapstructure:"max_size_mb"`               // Total size of all segments, 0 for no limit
+	MinFreeMB         int64         `json:"min_free_mb" mapstructure:"min_free_mb"`               // Free disk space to keep, 0 for no floor
+	RetentionInterval time.Duration `json:"retention_interval" mapstructure:"retention_interval"` // How often the retention policy is applied
+}
 
-	// Set defaults
-	setDefaults()
+// FFmpeg presets accepted by libx264
+var ffmpegPresets = map[string]bool{
+	"ultrafast": true, "superfast": true, "veryfast": true, "faster": true, "fast": true,
+	"medium": true, "slow": true, "slower": true, "veryslow": true, "placebo": true,
+}
 
-	// Read config file
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+// Merge returns the settings with the non-zero fields of override applied
+func (f FFmpegConfig) Merge(override FFmpegConfig) FFmpegConfig {
+	if override.Preset != "" {
+		f.Preset = override.Preset
+	}
+	if override.Tune != "" {
+		f.Tune = override.Tune
+	}
+	if override.CRF != 0 {
+		f.CRF = override.CRF
+	}
+	if override.MaxRate != "" {
+		f.MaxRate = override.MaxRate
+	}
+	if override.BufSize != "" {
+		f.BufSize = override.BufSize
+	}
+	if override.AudioBitrate != "" {
+		f.AudioBitrate = override.AudioBitrate
+	}
+	if override.AudioRate != 0 {
+		f.AudioRate = override.AudioRate
+	}
+	if override.VideoCodec != "" {
+		f.VideoCodec = override.VideoCodec
+	}
+	if override.AudioCodec != "" {
+		f.AudioCodec = override.AudioCodec
 	}
+	if override.LogLevel != "" {
+		f.LogLevel = override.LogLevel
+	}
+	if override.ExtraArgs != "" {
+		f.ExtraArgs = override.ExtraArgs
+	}
+	if override.StallTimeout != 0 {
+		f.StallTimeout = override.StallTimeout
+	}
+	return f
+}
 
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+// validate checks FFmpeg settings after profiles have been merged
+func (f *FFmpegConfig) validate() error {
+	if f.CRF < 0 || f.CRF > 51 {
+		return fmt.Errorf("invalid CRF %d, must be between 0 and 51", f.CRF)
+	}
+	if f.Preset != "" && !ffmpegPresets[f.Preset] {
+		return fmt.Errorf("invalid preset %q", f.Preset)
+	}
+	if f.AudioRate < 0 {
+		return fmt.Errorf("invalid audio rate %d", f.AudioRate)
+	}
+	if f.StallTimeout < 0 {
+		return fmt.Errorf("stall timeout must not be negative")
+	}
+	return nil
+}
+
+// CameraFFmpeg returns the FFmpeg settings of a camera: the global settings
+// with its named profile and inline overrides applied
+func (c *Config) CameraFFmpeg(camera *CameraConfig) (FFmpegConfig, error) {
+	settings := c.FFmpeg
+	if camera.FFmpegProfile != "" {
+		profile, exists := c.Profiles[strings.ToLower(camera.FFmpegProfile)]
+		if !exists {
+			return settings, fmt.Errorf("unknown FFmpeg profile %q", camera.FFmpegProfile)
+		}
+		settings = settings.Merge(profile)
+	}
+	if camera.FFmpeg != nil {
+		settings = settings.Merge(*camera.FFmpeg)
+	}
+	return settings, nil
+}
+
+// Output types
+const (
+	OutputTypeRTMP = "rtmp" // rtmp:// or rtmps:// URL, muxed as FLV
+	OutputTypeSRT  = "srt"  // srt:// URL, muxed as MPEG-TS
+	OutputTypeFile = "file" // Local file, muxer chosen by extension
+	OutputTypeHLS  = "hls"  // Local directory holding index.m3u8 and its segments
+)
+
+// HLS output defaults
+const (
+	DefaultHLSTime     = 2 * time.Second
+	DefaultHLSListSize = 6
+)
+
+// OutputConfig represents a destination a camera stream is sent to
+type OutputConfig struct {
+	Name        string        `json:"name" mapstructure:"name"`                   // Defaults to the type
+	Type        string        `json:"type" mapstructure:"type"`                   // rtmp, srt, file or hls
+	URL         string        `json:"url,omitempty" mapstructure:"url"`           // rtmp and srt outputs
+	Path        string        `json:"path,omitempty" mapstructure:"path"`         // File, or directory of an hls output
+	HLSTime     time.Duration `json:"hls_time,omitempty" mapstructure:"hls_time"` // Segment length of an hls output
+	HLSListSize int           `json:"hls_list_size,omitempty" mapstructure:"hls_list_size"`
+}
+
+// validate applies output defaults and checks the settings
+func (o *OutputConfig) validate() error {
+	o.Type = strings.ToLower(o.Type)
+	if o.Name == "" {
+		o.Name = o.Type
+	}
+
+	switch o.Type {
+	case OutputTypeRTMP, OutputTypeSRT:
+		u, err := url.Parse(o.URL)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		schemes := []string{"rtmp", "rtmps"}
+		if o.Type == OutputTypeSRT {
+			schemes = []string{"srt"}
+		}
+		if !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+			return fmt.Errorf("%s output needs a %s URL", o.Type, strings.Join(schemes, " or "))
+		}
+	case OutputTypeFile:
+		if o.Path == "" || filepath.Ext(o.Path) == "" {
+			return fmt.Errorf("file output needs a path with an extension")
+		}
+	case OutputTypeHLS:
+		if o.Path == "" {
+			return fmt.Errorf("hls output needs a directory")
+		}
+		if o.HLSTime <= 0 {
+			o.HLSTime = DefaultHLSTime
+		}
+		if o.HLSListSize <= 0 {
+			o.HLSListSize = DefaultHLSListSize
+		}
+	default:
+		return fmt.Errorf("invalid output type %q", o.Type)
+	}
+	return nil
+}
+
+// HLSOutputName names the output added for the built-in HLS server
+const HLSOutputName = "lan"
+
+// CameraOutputs returns the outputs of a camera, which default to the
+// camera's stream on the RTMP server. When the HLS server is enabled, cameras
+// without an hls output also write one below the HLS directory.
+func (c *Config) CameraOutputs(camera *CameraConfig) []OutputConfig {
+	outputs := camera.Outputs
+	if len(outputs) == 0 {
+		outputs = []OutputConfig{{
+			Name: OutputTypeRTMP,
+			Type: OutputTypeRTMP,
+			URL: fmt.Sprintf("rtmp://%s:%d/%s/%s",
+				c.RTMP.Host,
+				c.RTMP.Port,
+				c.RTMP.AppName,
+				camera.StreamID,
+			),
+		}}
+	}
+
+	if !c.HLS.Enabled || slices.ContainsFunc(outputs, func(o OutputConfig) bool { return o.Type == OutputTypeHLS }) {
+		return outputs
+	}
+	return append(slices.Clip(outputs), OutputConfig{
+		Name:        HLSOutputName,
+		Type:        OutputTypeHLS,
+		Path:        filepath.Join(c.HLS.Dir, camera.ID),
+		HLSTime:     DefaultHLSTime,
+		HLSListSize: DefaultHLSListSize,
+	})
+}
+
+// CameraHLSDir returns the directory holding a camera's HLS playlist
+func (c *Config) CameraHLSDir(camera *CameraConfig) (string, bool) {
+	for _, output := range c.CameraOutputs(camera) {
+		if output.Type == OutputTypeHLS {
+			return output.Path, true
+		}
+	}
+	return "", false
+}
+
+// DefaultHLSDir is where playlists for the built-in HLS server are written
+const DefaultHLSDir = "/var/lib/cctv-agent/hls"
+
+// HLSConfig represents the built-in HLS server, which serves the cameras'
+// hls outputs on the monitoring port
+type HLSConfig struct {
+	Enabled      bool   `json:"enabled" mapstructure:"enabled"`
+	Dir          string `json:"dir" mapstructure:"dir"`           // Root of the playlists of cameras without an hls output
+	Username     string `json:"username" mapstructure:"username"` // Basic auth credentials, no auth when empty
+	Password     string `json:"password" mapstructure:"password"`
+	PasswordFile string `json:"password_file,omitempty" mapstructure:"password_file"` // File holding the password, overrides password
+}
+
+// validate applies HLS server defaults and checks the settings
+func (h *HLSConfig) validate() error {
+	if h.Dir == "" {
+		h.Dir = DefaultHLSDir
+	}
+	if (h.Username == "") != (h.Password == "") {
+		return fmt.Errorf("username and password must be set together")
+	}
+	return nil
+}
+
+// DefaultStallTimeout is how long FFmpeg may go without progress before the
+// stream is restarted
+const DefaultStallTimeout = 30 * time.Second
+
+// Recording defaults
+const (
+	DefaultRecordingDir             = "/var/lib/cctv-agent/recordings"
+	DefaultRecordingFormat          = "mp4"
+	DefaultRecordingSegmentDuration = 5 * time.Minute
+	DefaultRetentionInterval        = time.Minute
+)
+
+// LoadConfig loads configuration from file
+func LoadConfig(path string) (*Config, error) {
+	config, err := ReadConfig(path)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate configuration
@@ -134,45 +414,70 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	return &config, nil
+	return config, nil
 }
 
-// LoadConfigFromJSON loads configuration from JSON data
+// ReadConfig reads a configuration file, applying environment overrides and
+// secret files, without validating it
+func ReadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseConfig(data)
+}
+
+// LoadConfigFromJSON loads configuration from JSON data. The data follows the
+// schema of the configuration file, e.g. durations may be given as "5s", and
+// environment overrides and secret files apply as when loading the file.
 func LoadConfigFromJSON(data []byte) (*Config, error) {
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	config, err := parseConfig(data)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	return &config, nil
+	return config, nil
 }
 
-// SaveConfig saves configuration to file
-func SaveConfig(config *Config, path string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
+// parseConfig decodes JSON configuration data with defaults, environment
+// overrides and secret files applied
+func parseConfig(data []byte) (*Config, error) {
+	// A fresh instance per load, so that settings removed from the file do
+	// not linger from a previous load
+	v := viper.New()
+	v.SetConfigType("json")
+
+	// Set defaults and environment overrides
+	setDefaults(v)
+	bindEnv(v)
+
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
 
-	if err := encoder.Encode(config); err != nil {
-		return fmt.Errorf("failed to encode config: %w", err)
+	if err := config.resolveSecretFiles(); err != nil {
+		return nil, fmt.Errorf("failed to read secret: %w", err)
 	}
 
-	return nil
+	return &config, nil
 }
 
-// Validate validates the configuration
+// Validate applies defaults and validates the configuration. Every problem
+// found is reported, joined into one error.
 func (c *Config) Validate() error {
+	var errs []error
+
 	if c.Agent.ID == "" {
-		return fmt.Errorf("agent ID is required")
+		errs = append(errs, fmt.Errorf("agent ID is required"))
 	}
 
 	if c.Agent.MaxConcurrency <= 0 {
@@ -180,32 +485,157 @@ func (c *Config) Validate() error {
 	}
 
 	if len(c.Cameras) == 0 {
-		return fmt.Errorf("at least one camera must be configured")
+		errs = append(errs, fmt.Errorf("at least one camera must be configured"))
 	}
 
 	for i, camera := range c.Cameras {
 		if camera.ID == "" {
-			return fmt.Errorf("camera[%d]: ID is required", i)
+			errs = append(errs, fmt.Errorf("camera[%d]: ID is required", i))
+		}
+		if camera.RTSPUrl == "" && camera.ONVIFHost == "" {
+			errs = append(errs, fmt.Errorf("camera[%d]: RTSP URL or ONVIF host is required", i))
+		}
+		if camera.PTZEnabled || camera.ONVIFHost != "" {
+			if _, err := camera.ONVIFEndpoint(); err != nil {
+				errs = append(errs, fmt.Errorf("camera[%d]: %w", i, err))
+			}
 		}
-		if camera.RTSPUrl == "" {
-			return fmt.Errorf("camera[%d]: RTSP URL is required", i)
+		switch strings.ToLower(camera.StreamMode) {
+		case "":
+			c.Cameras[i].StreamMode = StreamModeTranscode
+		case StreamModeTranscode, StreamModePassthrough:
+			c.Cameras[i].StreamMode = strings.ToLower(camera.StreamMode)
+		default:
+			errs = append(errs, fmt.Errorf("camera[%d]: invalid stream mode %q", i, camera.StreamMode))
 		}
-		if camera.RetryCount <= 0 {
-			c.Cameras[i].RetryCount = 3
+		names := make(map[string]bool, len(camera.Outputs))
+		for j := range c.Cameras[i].Outputs {
+			output := &c.Cameras[i].Outputs[j]
+			if err := output.validate(); err != nil {
+				errs = append(errs, fmt.Errorf("camera[%d]: output[%d]: %w", i, j, err))
+				continue
+			}
+			if names[output.Name] {
+				errs = append(errs, fmt.Errorf("camera[%d]: duplicate output name %q", i, output.Name))
+			}
+			names[output.Name] = true
+		}
+		if camera.RetryCount == 0 {
+			c.Cameras[i].RetryCount = DefaultRetryCount
 		}
 		if camera.RetryDelay <= 0 {
-			c.Cameras[i].RetryDelay = 5 * time.Second
+			c.Cameras[i].RetryDelay = DefaultRetryDelay
+		}
+		if camera.RetryMaxDelay <= 0 {
+			c.Cameras[i].RetryMaxDelay = max(DefaultRetryMaxDelay, c.Cameras[i].RetryDelay)
+		}
+		if c.Cameras[i].RetryMaxDelay < c.Cameras[i].RetryDelay {
+			errs = append(errs, fmt.Errorf("camera[%d]: retry max delay must not be less than retry delay", i))
+		}
+		if camera.RetryCooldown <= 0 {
+			c.Cameras[i].RetryCooldown = DefaultRetryCooldown
+		}
+		if camera.RecordingMaxAge < 0 {
+			errs = append(errs, fmt.Errorf("camera[%d]: recording max age must not be negative", i))
 		}
+		if (camera.Record || c.HLS.Enabled) && (camera.ID != filepath.Base(camera.ID) || camera.ID == "." || camera.ID == "..") {
+			errs = append(errs, fmt.Errorf("camera[%d]: ID %q cannot be used as a directory name", i, camera.ID))
+		}
+	}
+
+	if err := c.Recording.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("recording: %w", err))
+	}
+
+	if err := c.HLS.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("hls: %w", err))
 	}
 
 	if c.SocketIO.Host == "" {
-		return fmt.Errorf("Socket.IO host is required")
+		errs = append(errs, fmt.Errorf("Socket.IO host is required"))
 	}
 
 	if c.SocketIO.Port <= 0 {
 		c.SocketIO.Port = 8080
 	}
 
+	if c.FFmpeg.StallTimeout <= 0 {
+		c.FFmpeg.StallTimeout = DefaultStallTimeout
+	}
+	if err := c.FFmpeg.validate(); err != nil {
+		errs = append(errs, fmt.Errorf("ffmpeg: %w", err))
+	}
+
+	// Profile names are case-insensitive as viper lowercases map keys
+	profiles := make(map[string]FFmpegConfig, len(c.Profiles))
+	for name, profile := range c.Profiles {
+		key := strings.ToLower(name)
+		if _, exists := profiles[key]; exists {
+			errs = append(errs, fmt.Errorf("duplicate FFmpeg profile %q", name))
+		}
+		profiles[key] = profile
+	}
+	c.Profiles = profiles
+
+	for i := range c.Cameras {
+		settings, err := c.CameraFFmpeg(&c.Cameras[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("camera[%d]: %w", i, err))
+			continue
+		}
+		if err := settings.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("camera[%d]: ffmpeg: %w", i, err))
+		}
+	}
+
+	if c.Monitoring.HealthCheckInterval <= 0 {
+		c.Monitoring.HealthCheckInterval = 10 * time.Second
+	}
+	if c.Monitoring.MetricsPort <= 0 {
+		c.Monitoring.MetricsPort = 9090
+	}
+	if c.Monitoring.MetricsPort > 65535 {
+		errs = append(errs, fmt.Errorf("invalid metrics port: %d", c.Monitoring.MetricsPort))
+	}
+	if c.Monitoring.MinFreeDiskMB < 0 {
+		errs = append(errs, fmt.Errorf("min free disk space must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate applies recording defaults and checks the settings
+func (r *RecordingConfig) validate() error {
+	if r.Dir == "" {
+		r.Dir = DefaultRecordingDir
+	}
+	if r.Format == "" {
+		r.Format = DefaultRecordingFormat
+	}
+	if r.SegmentDuration <= 0 {
+		r.SegmentDuration = DefaultRecordingSegmentDuration
+	}
+	if r.RetentionInterval <= 0 {
+		r.RetentionInterval = DefaultRetentionInterval
+	}
+
+	r.Format = strings.ToLower(r.Format)
+	if r.Format != "mp4" && r.Format != "mkv" {
+		return fmt.Errorf("unsupported format: %s", r.Format)
+	}
+	if r.SegmentDuration < time.Second {
+		return fmt.Errorf("segment duration must be at least 1s")
+	}
+	if r.MaxAge < 0 {
+		return fmt.Errorf("max age must not be negative")
+	}
+	if r.MaxSizeMB < 0 {
+		return fmt.Errorf("max size must not be negative")
+	}
+	if r.MinFreeMB < 0 {
+		return fmt.Errorf("min free space must not be negative")
+	}
+
 	return nil
 }
 
@@ -219,6 +649,65 @@ func (c *Config) GetCameraByID(id string) (*CameraConfig, error) {
 	return nil, fmt.Errorf("camera not found: %s", id)
 }
 
+// ONVIFEndpoint returns the ONVIF device service endpoint of the camera.
+// Unset fields fall back to the host of the RTSP URL and the ONVIF defaults.
+func (c *CameraConfig) ONVIFEndpoint() (ONVIFEndpoint, error) {
+	endpoint := ONVIFEndpoint{
+		Scheme: strings.ToLower(c.ONVIFScheme),
+		Host:   c.ONVIFHost,
+		Port:   c.ONVIFPort,
+		Path:   c.ONVIFPath,
+	}
+
+	if endpoint.Host == "" {
+		if c.RTSPUrl == "" {
+			return endpoint, fmt.Errorf("ONVIF host or RTSP URL is required")
+		}
+		u, err := url.Parse(c.RTSPUrl)
+		if err != nil {
+			return endpoint, fmt.Errorf("invalid RTSP URL: %w", err)
+		}
+		endpoint.Host = u.Hostname()
+		if endpoint.Host == "" {
+			return endpoint, fmt.Errorf("RTSP URL has no host")
+		}
+	}
+	if endpoint.Scheme == "" {
+		endpoint.Scheme = DefaultONVIFScheme
+	}
+	if endpoint.Port == 0 {
+		endpoint.Port = DefaultONVIFPort
+	}
+	if endpoint.Path == "" {
+		endpoint.Path = DefaultONVIFPath
+	}
+
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return endpoint, fmt.Errorf("unsupported ONVIF scheme: %s", endpoint.Scheme)
+	}
+	if strings.ContainsAny(endpoint.Host, "/@") || (strings.Contains(endpoint.Host, ":") && net.ParseIP(endpoint.Host) == nil) {
+		return endpoint, fmt.Errorf("invalid ONVIF host: %s", endpoint.Host)
+	}
+	if endpoint.Port < 1 || endpoint.Port > 65535 {
+		return endpoint, fmt.Errorf("invalid ONVIF port: %d", endpoint.Port)
+	}
+	if !strings.HasPrefix(endpoint.Path, "/") {
+		return endpoint, fmt.Errorf("ONVIF path must start with /: %s", endpoint.Path)
+	}
+
+	return endpoint, nil
+}
+
+// HostPort returns the host and port of the endpoint
+func (e ONVIFEndpoint) HostPort() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// URL returns the device service URL of the endpoint
+func (e ONVIFEndpoint) URL() string {
+	return fmt.Sprintf("%s://%s%s", e.Scheme, e.HostPort(), e.Path)
+}
+
 // GetEnabledCameras returns all enabled cameras
 func (c *Config) GetEnabledCameras() []CameraConfig {
 	var enabled []CameraConfig
@@ -231,40 +720,53 @@ func (c *Config) GetEnabledCameras() []CameraConfig {
 }
 
 // setDefaults sets default configuration values
-func setDefaults() {
-	viper.SetDefault("agent.max_concurrency", 4)
-	viper.SetDefault("agent.update_interval", "30s")
-	viper.SetDefault("agent.log_level", "info")
-
-	viper.SetDefault("socketio.host", "localhost")
-	viper.SetDefault("socketio.port", 8080)
-	viper.SetDefault("socketio.path", "/socket.io")
-	viper.SetDefault("socketio.reconnect_delay", "5s")
-	viper.SetDefault("socketio.ping_interval", "30s")
-	viper.SetDefault("socketio.tls", false)
-
-	viper.SetDefault("ffmpeg.preset", "veryfast")
-	viper.SetDefault("ffmpeg.tune", "zerolatency")
-	viper.SetDefault("ffmpeg.crf", 28)
-	viper.SetDefault("ffmpeg.max_rate", "800k")
-	viper.SetDefault("ffmpeg.buf_size", "1600k")
-	viper.SetDefault("ffmpeg.audio_bitrate", "96k")
-	viper.SetDefault("ffmpeg.audio_rate", 44100)
-	viper.SetDefault("ffmpeg.video_codec", "libx264")
-	viper.SetDefault("ffmpeg.audio_codec", "aac")
-	viper.SetDefault("ffmpeg.log_level", "warning")
-
-	viper.SetDefault("monitoring.health_check_interval", "10s")
-	viper.SetDefault("monitoring.metrics_enabled", true)
-	viper.SetDefault("monitoring.metrics_port", 9090)
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("agent.max_concurrency", 4)
+	v.SetDefault("agent.update_interval", "30s")
+	v.SetDefault("agent.log_level", "info")
+
+	v.SetDefault("socketio.host", "localhost")
+	v.SetDefault("socketio.port", 8080)
+	v.SetDefault("socketio.path", "/socket.io")
+	v.SetDefault("socketio.reconnect_delay", "5s")
+	v.SetDefault("socketio.ping_interval", "30s")
+	v.SetDefault("socketio.tls", false)
+
+	v.SetDefault("ffmpeg.preset", "veryfast")
+	v.SetDefault("ffmpeg.tune", "zerolatency")
+	v.SetDefault("ffmpeg.crf", 28)
+	v.SetDefault("ffmpeg.max_rate", "800k")
+	v.SetDefault("ffmpeg.buf_size", "1600k")
+	v.SetDefault("ffmpeg.audio_bitrate", "96k")
+	v.SetDefault("ffmpeg.audio_rate", 44100)
+	v.SetDefault("ffmpeg.video_codec", "libx264")
+	v.SetDefault("ffmpeg.audio_codec", "aac")
+	v.SetDefault("ffmpeg.log_level", "warning")
+	v.SetDefault("ffmpeg.stall_timeout", "30s")
+
+	v.SetDefault("monitoring.health_check_interval", "10s")
+	v.SetDefault("monitoring.metrics_enabled", true)
+	v.SetDefault("monitoring.metrics_port", 9090)
+	v.SetDefault("monitoring.health_enabled", true)
+	v.SetDefault("monitoring.min_free_disk_mb", 256)
+
+	v.SetDefault("recording.dir", DefaultRecordingDir)
+	v.SetDefault("recording.format", DefaultRecordingFormat)
+	v.SetDefault("recording.segment_duration", "5m")
+	v.SetDefault("recording.max_age", "168h")
+	v.SetDefault("recording.min_free_mb", 512)
+	v.SetDefault("recording.retention_interval", "1m")
+
+	v.SetDefault("hls.enabled", false)
+	v.SetDefault("hls.dir", DefaultHLSDir)
 
 	// Updater defaults
-	viper.SetDefault("updater.enabled", true)
-	viper.SetDefault("updater.interval", "2h")
-	viper.SetDefault("updater.keep_releases", 3)
-	viper.SetDefault("updater.health_timeout", "30s")
-	viper.SetDefault("updater.base_dir", "/opt/cctv-agent")
-	viper.SetDefault("updater.service_name", "cctv-agent")
-	viper.SetDefault("updater.channel", "stable")
-	viper.SetDefault("updater.allow_downgrade", false)
+	v.SetDefault("updater.enabled", true)
+	v.SetDefault("updater.interval", "2h")
+	v.SetDefault("updater.keep_releases", 3)
+	v.SetDefault("updater.health_timeout", "30s")
+	v.SetDefault("updater.base_dir", "/opt/cctv-agent")
+	v.SetDefault("updater.service_name", "cctv-agent")
+	v.SetDefault("updater.channel", "stable")
+	v.SetDefault("updater.allow_downgrade", false)
 }
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// profileConfig returns a valid configuration with a global FFmpeg section
+// and one profile
+func profileConfig(cameras ...CameraConfig) *Config {
+	return &Config{
+		Agent:    AgentConfig{ID: "agent-1"},
+		SocketIO: SocketIOConfig{Host: "localhost", Port: 8080},
+		FFmpeg: FFmpegConfig{
+			Preset:       "veryfast",
+			CRF:          28,
+			MaxRate:      "800k",
+			AudioBitrate: "96k",
+			VideoCodec:   "libx264",
+			StallTimeout: 30 * time.Second,
+		},
+		Profiles: map[string]FFmpegConfig{
+			"Low-Bandwidth": {CRF: 32, MaxRate: "300k"},
+		},
+		Cameras: cameras,
+	}
+}
+
+func TestCameraFFmpegLayers(t *testing.T) {
+	tests := []struct {
+		name   string
+		camera CameraConfig
+		want   FFmpegConfig
+	}{
+		{
+			name:   "global only",
+			camera: CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream"},
+			want:   FFmpegConfig{Preset: "veryfast", CRF: 28, MaxRate: "800k", AudioBitrate: "96k", VideoCodec: "libx264"},
+		},
+		{
+			name:   "profile over global",
+			camera: CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream", FFmpegProfile: "low-bandwidth"},
+			want:   FFmpegConfig{Preset: "veryfast", CRF: 32, MaxRate: "300k", AudioBitrate: "96k", VideoCodec: "libx264"},
+		},
+		{
+			name: "inline over profile",
+			camera: CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream", FFmpegProfile: "LOW-BANDWIDTH",
+				FFmpeg: &FFmpegConfig{MaxRate: "500k", Preset: "ultrafast"}},
+			want: FFmpegConfig{Preset: "ultrafast", CRF: 32, MaxRate: "500k", AudioBitrate: "96k", VideoCodec: "libx264"},
+		},
+		{
+			name: "zero values do not override",
+			camera: CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream",
+				FFmpeg: &FFmpegConfig{CRF: 0, MaxRate: ""}},
+			want: FFmpegConfig{Preset: "veryfast", CRF: 28, MaxRate: "800k", AudioBitrate: "96k", VideoCodec: "libx264"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := profileConfig(tt.camera)
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := cfg.CameraFFmpeg(&cfg.Cameras[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			got.StallTimeout = 0
+			if got != tt.want {
+				t.Errorf("settings = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     func() *Config
+		wantErr string
+	}{
+		{
+			name: "unknown profile",
+			cfg: func() *Config {
+				return profileConfig(CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream", FFmpegProfile: "night"})
+			},
+			wantErr: `camera[0]: unknown FFmpeg profile "night"`,
+		},
+		{
+			name: "duplicate profile",
+			cfg: func() *Config {
+				cfg := profileConfig(CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream"})
+				cfg.Profiles["low-bandwidth"] = FFmpegConfig{CRF: 35}
+				return cfg
+			},
+			wantErr: "duplicate FFmpeg profile",
+		},
+		{
+			name: "invalid merged settings",
+			cfg: func() *Config {
+				cfg := profileConfig(CameraConfig{ID: "gate", RTSPUrl: "rtsp://10.0.0.2/stream", FFmpegProfile: "low-bandwidth"})
+				cfg.Profiles["Low-Bandwidth"] = FFmpegConfig{CRF: 60}
+				return cfg
+			},
+			wantErr: "camera[0]: ffmpeg: invalid CRF 60",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg().Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCameraFFmpegUnknownProfile(t *testing.T) {
+	cfg := profileConfig()
+	camera := &CameraConfig{ID: "gate", FFmpegProfile: "night"}
+
+	settings, err := cfg.CameraFFmpeg(camera)
+	if err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+	// The global settings are still returned
+	if settings != cfg.FFmpeg {
+		t.Errorf("settings = %+v, want the global settings", settings)
+	}
+}
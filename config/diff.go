@@ -0,0 +1,150 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Camera change actions
+const (
+	CameraAdded   = "added"
+	CameraRemoved = "removed"
+	CameraUpdated = "updated"
+)
+
+// CameraChange describes how a camera that streams or is controlled over
+// ONVIF differs between two configurations
+type CameraChange struct {
+	ID     string
+	Action string // added, removed or updated
+	// Settings is set when the camera's own entry changed, as opposed to
+	// global settings it inherits such as FFmpeg profiles or the RTMP server
+	Settings bool
+}
+
+// Diff describes the differences between two configurations
+type Diff struct {
+	Cameras   []CameraChange
+	LogLevel  bool
+	SocketIO  bool
+	Recording bool
+	// Sections whose changes only take effect after a restart
+	RestartRequired []string
+}
+
+// Empty reports whether the configurations are equivalent
+func (d *Diff) Empty() bool {
+	return len(d.Cameras) == 0 && !d.LogLevel && !d.SocketIO && !d.Recording && len(d.RestartRequired) == 0
+}
+
+// Changes returns a readable list of the differences
+func (d *Diff) Changes() []string {
+	changes := []string{}
+	for _, camera := range d.Cameras {
+		changes = append(changes, "cameras."+camera.ID+": "+camera.Action)
+	}
+	if d.LogLevel {
+		changes = append(changes, "logger.level")
+	}
+	if d.SocketIO {
+		changes = append(changes, "socketio")
+	}
+	if d.Recording {
+		changes = append(changes, "recording")
+	}
+	for _, section := range d.RestartRequired {
+		changes = append(changes, section+" (restart required)")
+	}
+	return changes
+}
+
+// LogLevel returns the effective log level, which falls back to the agent's
+// log level
+func (c *Config) LogLevel() string {
+	if c.Logger.Level != "" {
+		return c.Logger.Level
+	}
+	if c.Agent.LogLevel != "" {
+		return c.Agent.LogLevel
+	}
+	return "info"
+}
+
+// Compare returns the differences between c and next. Cameras are compared
+// by their effective stream settings, so a changed FFmpeg profile only
+// updates the cameras using it.
+func (c *Config) Compare(next *Config) *Diff {
+	diff := &Diff{
+		LogLevel:  c.LogLevel() != next.LogLevel(),
+		SocketIO:  !reflect.DeepEqual(c.SocketIO, next.SocketIO),
+		Recording: !reflect.DeepEqual(c.Recording, next.Recording),
+	}
+
+	oldCameras := activeCameras(c)
+	newCameras := activeCameras(next)
+	for id, camera := range newCameras {
+		old, exists := oldCameras[id]
+		switch {
+		case !exists:
+			diff.Cameras = append(diff.Cameras, CameraChange{ID: id, Action: CameraAdded, Settings: true})
+		case !reflect.DeepEqual(old, camera):
+			diff.Cameras = append(diff.Cameras, CameraChange{ID: id, Action: CameraUpdated, Settings: true})
+		case !c.sameStream(next, camera):
+			diff.Cameras = append(diff.Cameras, CameraChange{ID: id, Action: CameraUpdated})
+		}
+	}
+	for id := range oldCameras {
+		if _, exists := newCameras[id]; !exists {
+			diff.Cameras = append(diff.Cameras, CameraChange{ID: id, Action: CameraRemoved})
+		}
+	}
+	sort.Slice(diff.Cameras, func(i, j int) bool { return diff.Cameras[i].ID < diff.Cameras[j].ID })
+
+	// The log level is applied in place, the agent's other settings are not
+	oldAgent, newAgent := c.Agent, next.Agent
+	oldAgent.LogLevel, newAgent.LogLevel = "", ""
+	oldLogger, newLogger := c.Logger, next.Logger
+	oldLogger.Level, newLogger.Level = "", ""
+
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"agent", oldAgent, newAgent},
+		{"logger", oldLogger, newLogger},
+		{"monitoring", c.Monitoring, next.Monitoring},
+		{"hls", c.HLS, next.HLS},
+		{"updater", c.Updater, next.Updater},
+	}
+	for _, section := range sections {
+		if !reflect.DeepEqual(section.old, section.new) {
+			diff.RestartRequired = append(diff.RestartRequired, section.name)
+		}
+	}
+
+	return diff
+}
+
+// sameStream reports whether a camera streams with the same effective FFmpeg
+// settings and outputs under both configurations
+func (c *Config) sameStream(next *Config, camera *CameraConfig) bool {
+	oldFFmpeg, oldErr := c.CameraFFmpeg(camera)
+	newFFmpeg, newErr := next.CameraFFmpeg(camera)
+	if oldErr != nil || newErr != nil || oldFFmpeg != newFFmpeg {
+		return false
+	}
+	return reflect.DeepEqual(c.CameraOutputs(camera), next.CameraOutputs(camera))
+}
+
+// activeCameras indexes the cameras of a configuration that are enabled or,
+// while disabled, still connected to over ONVIF for PTZ, by ID
+func activeCameras(c *Config) map[string]*CameraConfig {
+	cameras := make(map[string]*CameraConfig, len(c.Cameras))
+	for i := range c.Cameras {
+		camera := &c.Cameras[i]
+		if camera.Enabled || camera.PTZEnabled || camera.ONVIFHost != "" {
+			cameras[camera.ID] = camera
+		}
+	}
+	return cameras
+}
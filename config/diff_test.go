@@ -0,0 +1,106 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareCameras(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			RTMP: RTMPConfig{Host: "rtmp.example.com", Port: 1935, AppName: "live"},
+			Profiles: map[string]FFmpegConfig{
+				"low": {CRF: 30},
+			},
+			Cameras: []CameraConfig{
+				{ID: "gate", Enabled: true, RTSPUrl: "rtsp://10.0.0.2/stream", FFmpegProfile: "low"},
+				{ID: "yard", Enabled: true, RTSPUrl: "rtsp://10.0.0.3/stream"},
+				{ID: "dome", PTZEnabled: true, RTSPUrl: "rtsp://10.0.0.4/stream", Username: "admin", Password: "old"},
+				{ID: "spare", RTSPUrl: "rtsp://10.0.0.5/stream"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []CameraChange
+	}{
+		{
+			name:   "unchanged",
+			change: func(c *Config) {},
+		},
+		{
+			name:   "camera settings",
+			change: func(c *Config) { c.Cameras[1].RTSPUrl = "rtsp://10.0.0.30/stream" },
+			want:   []CameraChange{{ID: "yard", Action: CameraUpdated, Settings: true}},
+		},
+		{
+			name:   "profile in use",
+			change: func(c *Config) { c.Profiles["low"] = FFmpegConfig{CRF: 35} },
+			want:   []CameraChange{{ID: "gate", Action: CameraUpdated}},
+		},
+		{
+			name:   "RTMP server",
+			change: func(c *Config) { c.RTMP.Port = 1936 },
+			want: []CameraChange{
+				{ID: "dome", Action: CameraUpdated},
+				{ID: "gate", Action: CameraUpdated},
+				{ID: "yard", Action: CameraUpdated},
+			},
+		},
+		{
+			name:   "PTZ-only camera credentials",
+			change: func(c *Config) { c.Cameras[2].Password = "new" },
+			want:   []CameraChange{{ID: "dome", Action: CameraUpdated, Settings: true}},
+		},
+		{
+			name:   "disabled camera without ONVIF",
+			change: func(c *Config) { c.Cameras[3].RTSPUrl = "rtsp://10.0.0.50/stream" },
+		},
+		{
+			name:   "camera enabled",
+			change: func(c *Config) { c.Cameras[3].Enabled = true },
+			want:   []CameraChange{{ID: "spare", Action: CameraAdded, Settings: true}},
+		},
+		{
+			name:   "PTZ turned off on a disabled camera",
+			change: func(c *Config) { c.Cameras[2].PTZEnabled = false },
+			want:   []CameraChange{{ID: "dome", Action: CameraRemoved}},
+		},
+		{
+			name:   "camera removed",
+			change: func(c *Config) { c.Cameras = c.Cameras[1:] },
+			want:   []CameraChange{{ID: "gate", Action: CameraRemoved}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base()
+			tt.change(next)
+
+			diff := base().Compare(next)
+			if !reflect.DeepEqual(diff.Cameras, tt.want) {
+				t.Errorf("camera changes = %+v, want %+v", diff.Cameras, tt.want)
+			}
+			if len(tt.want) == 0 && !diff.Empty() {
+				t.Errorf("diff is not empty: %v", diff.Changes())
+			}
+		})
+	}
+}
+
+func TestCompareSections(t *testing.T) {
+	old := &Config{Logger: LoggerConfig{Level: "info"}}
+	next := &Config{Logger: LoggerConfig{Level: "debug"}, SocketIO: SocketIOConfig{Host: "server"}}
+	next.Monitoring.MetricsPort = 9100
+
+	diff := old.Compare(next)
+	if !diff.LogLevel || !diff.SocketIO || diff.Recording {
+		t.Errorf("diff = %+v", diff)
+	}
+	if !reflect.DeepEqual(diff.RestartRequired, []string{"monitoring"}) {
+		t.Errorf("restart required for %v, want monitoring", diff.RestartRequired)
+	}
+}
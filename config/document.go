@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Document is the content of a configuration file as written, before
+// defaults, environment overrides and secret files are applied. Updates are
+// made to the document so that saving them writes only what the file set.
+type Document map[string]interface{}
+
+// ReadDocument reads a configuration file as a document. A missing file is
+// an empty document.
+func ReadDocument(path string) (Document, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Document{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseDocument(data)
+}
+
+// parseDocument decodes a JSON object into a document
+func parseDocument(data []byte) (Document, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("config must be a JSON object")
+	}
+	return Document(obj), nil
+}
+
+// Config returns the validated configuration the document describes, with
+// defaults, environment overrides and secret files applied as when loading
+// the file
+func (d Document) Config() (*Config, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return LoadConfigFromJSON(data)
+}
+
+// clone returns a deep copy of the document
+func (d Document) clone() Document {
+	return Document(cloneValue(map[string]interface{}(d)).(map[string]interface{}))
+}
+
+// cloneValue deep copies a decoded JSON value
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			c[key] = cloneValue(item)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, item := range v {
+			c[i] = cloneValue(item)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// configDocument returns a configuration as a document
+func configDocument(c *Config) (Document, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return parseDocument(data)
+}
+
+// object returns the object at a path of keys, or nil if there is none
+func (d Document) object(path []string) map[string]interface{} {
+	obj := map[string]interface{}(d)
+	for _, key := range path {
+		obj, _ = obj[key].(map[string]interface{})
+	}
+	return obj
+}
+
+// cameras returns the camera objects of the document
+func (d Document) cameras() []map[string]interface{} {
+	items, _ := d["cameras"].([]interface{})
+	cameras := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if camera, ok := item.(map[string]interface{}); ok {
+			cameras = append(cameras, camera)
+		}
+	}
+	return cameras
+}
+
+// camera returns the object of the camera with the given ID, or nil if there
+// is none
+func (d Document) camera(id string) map[string]interface{} {
+	for _, camera := range d.cameras() {
+		if cameraID, _ := camera["id"].(string); cameraID == id {
+			return camera
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variables overriding settings
+const EnvPrefix = "CCTV"
+
+// bindEnv lets environment variables override settings, e.g. CCTV_SOCKETIO_HOST
+// overrides socketio.host. Settings within lists and maps, such as the
+// cameras and FFmpeg profiles, cannot be overridden.
+func bindEnv(v *viper.Viper) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range settingKeys(reflect.TypeOf(Config{}), "") {
+		v.BindEnv(key)
+	}
+}
+
+// settingKeys returns the keys of the scalar settings of a configuration
+// struct
+func settingKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + name
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			keys = append(keys, settingKeys(field.Type, key+".")...)
+		case reflect.Slice, reflect.Map, reflect.Pointer:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// envName returns the environment variable overriding a setting, as bound by
+// bindEnv
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// restoreEnvOverrides keeps the settings overridden by environment variables
+// out of a patched document, see restoreOverlay
+func restoreEnvOverrides(merged, orig Document, current *Config) error {
+	live, err := configDocument(current)
+	if err != nil {
+		return err
+	}
+	redacted, err := configDocument(current.Redacted())
+	if err != nil {
+		return err
+	}
+
+	for _, key := range settingKeys(reflect.TypeOf(Config{}), "") {
+		// Like viper, ignore empty variables
+		if os.Getenv(envName(key)) == "" {
+			continue
+		}
+		path := strings.Split(key, ".")
+		parent, name := path[:len(path)-1], path[len(path)-1]
+		obj := merged.object(parent)
+		if obj == nil {
+			continue
+		}
+		restoreOverlay(obj, orig.object(parent), name, live.object(parent)[name], redacted.object(parent)[name])
+	}
+	return nil
+}
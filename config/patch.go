@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Patch returns the document resulting from applying a JSON merge patch
+// (RFC 7386) to d: objects are merged, null removes a setting and arrays such
+// as the cameras are replaced as a whole. A complete configuration is
+// therefore a valid patch too. Redacted secrets in the patch keep their
+// values in current, the running configuration, and settings it takes from
+// environment variables or secret files are not copied into the document
+// unless the patch changes them. The document itself is not modified.
+func (d Document) Patch(patch []byte, current *Config) (Document, error) {
+	var patchDoc interface{}
+	if err := json.Unmarshal(patch, &patchDoc); err != nil {
+		return nil, fmt.Errorf("failed to parse config patch: %w", err)
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("config patch must be a JSON object")
+	}
+
+	merged := Document(mergePatch(map[string]interface{}(d.clone()), patchDoc).(map[string]interface{}))
+	if err := restoreEnvOverrides(merged, d, current); err != nil {
+		return nil, err
+	}
+	restoreSecretFiles(merged, d, current)
+	restoreRedacted(merged, current)
+	return merged, nil
+}
+
+// restoreOverlay keeps a setting that the running configuration takes from
+// elsewhere than the file, such as an environment variable, out of a patched
+// document object. If the patch left the setting as it is running, or
+// redacted, the original object's setting is restored, or the setting is
+// removed if the original has none.
+func restoreOverlay(obj, orig map[string]interface{}, key string, live, redacted interface{}) {
+	value, ok := obj[key]
+	if !ok || !(reflect.DeepEqual(value, live) || reflect.DeepEqual(value, redacted)) {
+		return
+	}
+	if origValue, ok := orig[key]; ok {
+		obj[key] = origValue
+	} else {
+		delete(obj, key)
+	}
+}
+
+// mergePatch applies a JSON merge patch to a decoded JSON document
+func mergePatch(doc, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	docObj, ok := doc.(map[string]interface{})
+	if !ok {
+		docObj = make(map[string]interface{})
+	}
+	for key, value := range patchObj {
+		if value == nil {
+			delete(docObj, key)
+			continue
+		}
+		docObj[key] = mergePatch(docObj[key], value)
+	}
+	return docObj
+}
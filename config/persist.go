@@ -0,0 +1,182 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	// MaxConfigBackups is the number of backups kept next to the
+	// configuration file
+	MaxConfigBackups = 5
+
+	// backupTimeFormat stamps backups so that they sort oldest first
+	backupTimeFormat = "20060102T150405.000Z"
+)
+
+// SaveConfig saves configuration to file. The file is replaced atomically
+// so that a power loss leaves either the old or the new configuration, and
+// the replaced file is kept as a timestamped backup. Secrets read from files
+// are not written.
+func SaveConfig(config *Config, path string) error {
+	return saveFile(config.withoutSecretFiles(), path)
+}
+
+// SaveDocument saves a configuration document to file, replacing and
+// backing up the file as SaveConfig does
+func SaveDocument(doc Document, path string) error {
+	return saveFile(doc, path)
+}
+
+// saveFile writes a configuration as indented JSON, backing up the file it
+// replaces and pruning old backups
+func saveFile(config interface{}, path string) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	data = append(data, '\n')
+
+	mode := fs.FileMode(0644)
+	current, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := writeFileAtomic(backupPath(path, time.Now()), current, mode); err != nil {
+			return fmt.Errorf("failed to back up config file: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("failed to back up config file: %w", err)
+	}
+
+	if err := writeFileAtomic(path, data, mode); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	// Failing to remove old backups does not affect the saved configuration
+	backups, _ := ConfigBackups(path)
+	for len(backups) > MaxConfigBackups {
+		os.Remove(backups[len(backups)-1])
+		backups = backups[:len(backups)-1]
+	}
+
+	return nil
+}
+
+// backupPath returns a free path for a backup of a configuration file made
+// at the given time. Saves within the same millisecond get later stamps, so
+// that backups still sort in the order they were made.
+func backupPath(path string, t time.Time) string {
+	for {
+		backup := fmt.Sprintf("%s.%s.bak", path, t.UTC().Format(backupTimeFormat))
+		if _, err := os.Lstat(backup); err != nil {
+			return backup
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+// ConfigBackups returns the backups of a configuration file, newest first
+func ConfigBackups(path string) ([]string, error) {
+	backups, err := filepath.Glob(path + ".*.bak")
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// LoadLatestBackup loads the newest backup of a configuration file that is
+// valid, and returns its path
+func LoadLatestBackup(path string) (*Config, string, error) {
+	backups, err := ConfigBackups(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list config backups: %w", err)
+	}
+
+	var errs []error
+	for _, backup := range backups {
+		config, err := LoadConfig(backup)
+		if err == nil {
+			return config, backup, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(backup), err))
+	}
+	if len(errs) == 0 {
+		return nil, "", fmt.Errorf("no config backups of %s", path)
+	}
+	return nil, "", fmt.Errorf("no valid config backup: %w", errors.Join(errs...))
+}
+
+// LatestBackupDocument reads the newest backup of a configuration file that
+// is valid as a document, and returns its path
+func LatestBackupDocument(path string) (Document, string, error) {
+	_, backup, err := LoadLatestBackup(path)
+	if err != nil {
+		return nil, "", err
+	}
+	doc, err := ReadDocument(backup)
+	if err != nil {
+		return nil, "", err
+	}
+	return doc, backup, nil
+}
+
+// RestoreBackup replaces a configuration file with one of its backups. The
+// backup is moved rather than copied and the replaced file is not backed up,
+// so that restoring the newest backup again goes one more save back.
+func RestoreBackup(backup, path string) error {
+	if err := os.Rename(backup, path); err != nil {
+		return fmt.Errorf("failed to restore config backup: %w", err)
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// writeFileAtomic replaces a file by writing a temporary file in the same
+// directory, syncing it to disk and renaming it over the file
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir syncs a directory so that a rename in it survives a power loss
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
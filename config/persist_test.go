@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// validConfig is a minimal valid configuration file for the given agent
+func validConfig(agentID string) string {
+	return `{"agent": {"id": "` + agentID + `"}, "cameras": [{"id": "gate", "rtsp_url": "rtsp://10.0.0.2/stream"}]}`
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLatestBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	writeFile(t, path, `{"agent": `)
+	older := path + ".20240101T120000.000Z.bak"
+	newer := path + ".20240102T120000.000Z.bak"
+	writeFile(t, older, validConfig("from-backup"))
+	writeFile(t, newer, `not json`)
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("expected the corrupt config file to fail to load")
+	}
+
+	// The corrupt newest backup is skipped
+	cfg, backup, err := LoadLatestBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup != older {
+		t.Errorf("loaded backup %s, want %s", backup, older)
+	}
+	if cfg.Agent.ID != "from-backup" {
+		t.Errorf("agent ID = %q, want the backup's", cfg.Agent.ID)
+	}
+}
+
+func TestLoadLatestBackupNone(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	if _, _, err := LoadLatestBackup(path); err == nil || !strings.Contains(err.Error(), "no config backups") {
+		t.Errorf("error = %v, want no config backups", err)
+	}
+
+	writeFile(t, path+".20240101T120000.000Z.bak", `{}`)
+	if _, _, err := LoadLatestBackup(path); err == nil || !strings.Contains(err.Error(), "no valid config backup") {
+		t.Errorf("error = %v, want no valid config backup", err)
+	}
+}
+
+func TestSaveConfigBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	// Saves follow each other faster than the backup timestamps tick
+	const saves = MaxConfigBackups + 3
+	for i := 0; i < saves; i++ {
+		cfg, err := LoadConfigFromJSON([]byte(validConfig(string(rune('a' + i)))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveConfig(cfg, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := ConfigBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != MaxConfigBackups {
+		t.Fatalf("%d backups kept, want %d", len(backups), MaxConfigBackups)
+	}
+
+	// Backups are newest first, and the oldest were pruned
+	for i, backup := range backups {
+		cfg, err := LoadConfig(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := string(rune('a' + saves - 2 - i)); cfg.Agent.ID != want {
+			t.Errorf("backup %d holds agent %q, want %q", i, cfg.Agent.ID, want)
+		}
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := string(rune('a' + saves - 1)); cfg.Agent.ID != want {
+		t.Errorf("saved agent %q, want %q", cfg.Agent.ID, want)
+	}
+}
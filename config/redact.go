@@ -0,0 +1,135 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RedactedValue replaces secrets in a redacted configuration
+const RedactedValue = "xxxxx" // As in url.URL.Redacted
+
+// secretQueryParams are URL query parameters holding secrets, such as the
+// passphrase of an SRT output
+var secretQueryParams = []string{"passphrase", "password", "token"}
+
+// Redacted returns a copy of the configuration with passwords, and the
+// passwords embedded in URLs, replaced by RedactedValue
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.HLS.Password = redactSecret(c.HLS.Password)
+
+	redacted.Cameras = make([]CameraConfig, len(c.Cameras))
+	for i, camera := range c.Cameras {
+		camera.Password = redactSecret(camera.Password)
+		camera.RTSPUrl = redactURL(camera.RTSPUrl)
+		if camera.Outputs != nil {
+			outputs := make([]OutputConfig, len(camera.Outputs))
+			for j, output := range camera.Outputs {
+				output.URL = redactURL(output.URL)
+				outputs[j] = output
+			}
+			camera.Outputs = outputs
+		}
+		redacted.Cameras[i] = camera
+	}
+	if c.Cameras == nil {
+		redacted.Cameras = nil
+	}
+
+	return &redacted
+}
+
+// restoreRedacted replaces secrets in a document that were sent back
+// redacted, e.g. after editing the output of Redacted, with their values in
+// the current configuration. Cameras are matched by ID and outputs by name.
+func restoreRedacted(doc Document, current *Config) {
+	if hls := doc.object([]string{"hls"}); hls != nil {
+		restoreSetting(hls, "password", current.HLS.Password, redactSecret)
+	}
+
+	for _, camera := range doc.cameras() {
+		id, _ := camera["id"].(string)
+		old, err := current.GetCameraByID(id)
+		if err != nil {
+			continue
+		}
+		restoreSetting(camera, "password", old.Password, redactSecret)
+		restoreSetting(camera, "rtsp_url", old.RTSPUrl, redactURL)
+
+		outputs, _ := camera["outputs"].([]interface{})
+		for _, item := range outputs {
+			output, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name := outputName(output)
+			for _, oldOutput := range old.Outputs {
+				if oldOutput.Name == name {
+					restoreSetting(output, "url", oldOutput.URL, redactURL)
+					break
+				}
+			}
+		}
+	}
+}
+
+// restoreSetting restores a redacted string setting of a document object
+func restoreSetting(obj map[string]interface{}, key, current string, redact func(string) string) {
+	if value, ok := obj[key].(string); ok {
+		obj[key] = restoreSecret(value, current, redact)
+	}
+}
+
+// outputName returns the name of an output in a document, which defaults to
+// its type
+func outputName(output map[string]interface{}) string {
+	if name, _ := output["name"].(string); name != "" {
+		return name
+	}
+	outputType, _ := output["type"].(string)
+	return strings.ToLower(outputType)
+}
+
+// restoreSecret returns current if value is its redacted form
+func restoreSecret(value, current string, redact func(string) string) string {
+	if value != current && value == redact(current) {
+		return current
+	}
+	return value
+}
+
+// redactSecret hides a non-empty secret
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return RedactedValue
+}
+
+// redactURL hides the password and secret query parameters of a URL
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		// Unparseable URLs may still hold credentials
+		return RedactedValue
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), RedactedValue)
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for _, param := range secretQueryParams {
+			if query.Has(param) {
+				query.Set(param, RedactedValue)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
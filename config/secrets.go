@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// resolveSecretFiles reads the secrets named by *_file settings, which take
+// precedence over the settings themselves. Environment variables in the
+// paths are expanded, so that e.g. ${CREDENTIALS_DIRECTORY}/camera1 reads a
+// systemd credential.
+func (c *Config) resolveSecretFiles() error {
+	var errs []error
+	resolve := func(name, path string, value *string) {
+		if path == "" {
+			return
+		}
+		secret, err := readSecretFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		*value = secret
+	}
+
+	resolve("hls: password_file", c.HLS.PasswordFile, &c.HLS.Password)
+	for i := range c.Cameras {
+		camera := &c.Cameras[i]
+		resolve(fmt.Sprintf("camera[%d]: password_file", i), camera.PasswordFile, &camera.Password)
+		resolve(fmt.Sprintf("camera[%d]: rtsp_url_file", i), camera.RTSPUrlFile, &camera.RTSPUrl)
+	}
+
+	return errors.Join(errs...)
+}
+
+// withoutSecretFiles returns a copy of the configuration without the
+// secrets read from files, so that saving it does not write them out
+func (c *Config) withoutSecretFiles() *Config {
+	stripped := *c
+	if stripped.HLS.PasswordFile != "" {
+		stripped.HLS.Password = ""
+	}
+
+	if c.Cameras != nil {
+		stripped.Cameras = make([]CameraConfig, len(c.Cameras))
+		for i, camera := range c.Cameras {
+			if camera.PasswordFile != "" {
+				camera.Password = ""
+			}
+			if camera.RTSPUrlFile != "" {
+				camera.RTSPUrl = ""
+			}
+			stripped.Cameras[i] = camera
+		}
+	}
+
+	return &stripped
+}
+
+// restoreSecretFiles keeps the secrets read from files out of a patched
+// document, see restoreOverlay
+func restoreSecretFiles(merged, orig Document, current *Config) {
+	hls := []string{"hls"}
+	if current.HLS.PasswordFile != "" && merged.object(hls) != nil {
+		restoreOverlay(merged.object(hls), orig.object(hls), "password",
+			current.HLS.Password, redactSecret(current.HLS.Password))
+	}
+
+	for _, camera := range merged.cameras() {
+		id, _ := camera["id"].(string)
+		live, err := current.GetCameraByID(id)
+		if err != nil {
+			continue
+		}
+		origCamera := orig.camera(id)
+		if live.PasswordFile != "" {
+			restoreOverlay(camera, origCamera, "password", live.Password, redactSecret(live.Password))
+		}
+		if live.RTSPUrlFile != "" {
+			restoreOverlay(camera, origCamera, "rtsp_url", live.RTSPUrl, redactURL(live.RTSPUrl))
+		}
+	}
+}
+
+// readSecretFile reads a secret from a file, without the trailing newline
+// most tools write
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(os.ExpandEnv(path))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -5,6 +5,7 @@ go 1.24.1
 toolchain go1.24.2
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/hashicorp/go-version v1.6.0
 	github.com/shirou/gopsutil/v3 v3.23.12
 	github.com/spf13/pflag v1.0.7
@@ -13,6 +14,7 @@ require (
 	github.com/zishang520/engine.io-client-go v1.1.0
 	github.com/zishang520/engine.io/v2 v2.5.0
 	github.com/zishang520/socket.io-client-go v1.1.0
+	github.com/zishang520/socket.io/v2 v2.5.0
 	go.uber.org/zap v1.26.0
 	golang.org/x/sync v0.12.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
@@ -22,7 +24,6 @@ require (
 	github.com/andybalholm/brotli v1.2.0 // indirect
 	github.com/beevik/etree v1.1.0 // indirect
 	github.com/elgs/gostrgen v0.0.0-20161222160715-9d61ae07eeae // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/gofrs/uuid v3.2.0+incompatible // indirect
 	github.com/gookit/color v1.5.4 // indirect
@@ -52,7 +53,6 @@ require (
 	github.com/yusufpapurcu/wmi v1.2.3 // indirect
 	github.com/zishang520/engine.io-go-parser v1.3.2 // indirect
 	github.com/zishang520/socket.io-go-parser/v2 v2.5.0 // indirect
-	github.com/zishang520/socket.io/v2 v2.5.0 // indirect
 	github.com/zishang520/webtransport-go v0.9.1 // indirect
 	go.uber.org/mock v0.5.1 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/cctv-agent/internal/health"
+	"github.com/cctv-agent/internal/stream"
+)
+
+// registerHealthChecks registers the checks served on /healthz and /readyz
+func (app *Application) registerHealthChecks() {
+	app.healthChecker.Register(health.Check{
+		Name:     "stream_manager",
+		Critical: true,
+		Run:      app.checkStreamManager,
+	})
+	app.healthChecker.Register(health.Check{
+		Name:     "ffmpeg",
+		Critical: true,
+		Run:      checkFFmpeg,
+	})
+	app.healthChecker.Register(health.Check{
+		Name: "streams",
+		Run:  app.checkStreams,
+	})
+	app.healthChecker.Register(health.Check{
+		Name: "socketio",
+		Run:  app.checkSocketIO,
+	})
+	app.healthChecker.Register(health.Check{
+		Name: "disk",
+		Run:  app.checkDisk,
+	})
+}
+
+// checkStreamManager fails when the stream manager has stopped
+func (app *Application) checkStreamManager(ctx context.Context) error {
+	if !app.streamManager.IsRunning() {
+		return fmt.Errorf("stream manager is not running")
+	}
+	return nil
+}
+
+// checkStreams fails when no stream is connected
+func (app *Application) checkStreams(ctx context.Context) error {
+	streams := app.streamManager.GetStatus()
+	if len(streams) == 0 {
+		return nil
+	}
+	for _, status := range streams {
+		if status == stream.StatusConnected {
+			return nil
+		}
+	}
+	return fmt.Errorf("none of %d streams is connected", len(streams))
+}
+
+// checkSocketIO fails while the Socket.IO connection is down
+func (app *Application) checkSocketIO(ctx context.Context) error {
+	if !app.sioClient.IsConnected() {
+		return fmt.Errorf("not connected to Socket.IO server")
+	}
+	return nil
+}
+
+// checkDisk fails when the root or recording filesystem is low on space
+func (app *Application) checkDisk(ctx context.Context) error {
+	cfg := app.getConfig()
+	minFree := uint64(cfg.Monitoring.MinFreeDiskMB) << 20
+	if minFree == 0 {
+		return nil
+	}
+
+	paths := []string{"/"}
+	if len(app.recorder.GetRecorderInfo()) > 0 {
+		paths = append(paths, cfg.Recording.Dir)
+	}
+
+	for _, path := range paths {
+		usage, err := app.systemMonitor.GetPathDiskUsage(path)
+		if err != nil {
+			return fmt.Errorf("failed to get disk usage of %s: %w", path, err)
+		}
+		if usage.Free < minFree {
+			return fmt.Errorf("%s has %d MB free, below %d MB", path, usage.Free>>20, minFree>>20)
+		}
+	}
+	return nil
+}
+
+// checkFFmpeg fails when the FFmpeg binary cannot be found
+func checkFFmpeg(ctx context.Context) error {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	return nil
+}
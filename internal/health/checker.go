@@ -0,0 +1,206 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cctv-agent/internal/logger"
+)
+
+const (
+	// DefaultInterval is used when no evaluation interval is configured
+	DefaultInterval = 10 * time.Second
+
+	// checkTimeout bounds how long a single check may take
+	checkTimeout = 5 * time.Second
+)
+
+// Status represents the outcome of a check
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+)
+
+// Check evaluates one aspect of the agent's health. A failing critical
+// check makes the agent unhealthy; any failing check makes it not ready.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a single check
+type CheckResult struct {
+	Status   Status        `json:"status"`
+	Critical bool          `json:"critical"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Since    time.Time     `json:"since"`
+}
+
+// Report is the outcome of a health evaluation
+type Report struct {
+	Healthy   bool                   `json:"healthy"`
+	Ready     bool                   `json:"ready"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Checks    map[string]CheckResult `json:"checks"`
+}
+
+// Checker periodically evaluates the registered checks
+type Checker struct {
+	interval time.Duration
+	logger   logger.Logger
+	checks   []Check
+	report   Report
+	mu       sync.RWMutex
+}
+
+// NewChecker creates a health checker evaluating every interval
+func NewChecker(interval time.Duration, log logger.Logger) *Checker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Checker{
+		interval: interval,
+		logger:   log,
+	}
+}
+
+// Register adds a check to the checker
+func (c *Checker) Register(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Run evaluates the checks every interval until the context is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.Evaluate(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate runs all checks once and stores the resulting report
+func (c *Checker) Evaluate(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.checks...)
+	previous := c.report.Checks
+	c.mu.RUnlock()
+
+	report := Report{
+		Healthy:   true,
+		Ready:     true,
+		CheckedAt: time.Now(),
+		Checks:    make(map[string]CheckResult, len(checks)),
+	}
+
+	for _, check := range checks {
+		result := runCheck(ctx, check)
+
+		prev, seen := previous[check.Name]
+		if seen && prev.Status == result.Status {
+			result.Since = prev.Since
+		} else {
+			result.Since = report.CheckedAt
+			if result.Status == StatusFail {
+				c.logger.Warn("Health check failing", "check", check.Name, "error", result.Error)
+			} else if seen {
+				c.logger.Info("Health check recovered", "check", check.Name)
+			}
+		}
+
+		if result.Status == StatusFail {
+			report.Ready = false
+			if check.Critical {
+				report.Healthy = false
+			}
+		}
+		report.Checks[check.Name] = result
+	}
+
+	c.mu.Lock()
+	c.report = report
+	c.mu.Unlock()
+
+	return report
+}
+
+// runCheck runs a single check with a timeout
+func runCheck(ctx context.Context, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+
+	result := CheckResult{
+		Status:   StatusPass,
+		Critical: check.Critical,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// GetReport returns the last health report
+func (c *Checker) GetReport() Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.report
+}
+
+// Ready reports whether the checks have been evaluated and all passed
+func (c *Checker) Ready() bool {
+	report := c.GetReport()
+	return report.Ready && !report.CheckedAt.IsZero()
+}
+
+// LivenessHandler serves the last report, responding 503 when a critical
+// check fails
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.GetReport()
+		c.writeReport(w, report, report.Healthy && !report.CheckedAt.IsZero())
+	})
+}
+
+// ReadinessHandler serves the last report, responding 503 when any check
+// fails
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.GetReport()
+		c.writeReport(w, report, report.Ready && !report.CheckedAt.IsZero())
+	})
+}
+
+// writeReport writes a report as JSON with a status code reflecting ok
+func (c *Checker) writeReport(w http.ResponseWriter, report Report, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		c.logger.Debug("Failed to write health report", "error", err)
+	}
+}
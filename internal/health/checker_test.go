@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cctv-agent/internal/logger"
+)
+
+// checkFunc returns a check function failing with err
+func checkFunc(err error) func(ctx context.Context) error {
+	return func(ctx context.Context) error { return err }
+}
+
+func TestEvaluate(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name        string
+		critical    error
+		nonCritical error
+		healthy     bool
+		ready       bool
+	}{
+		{"all passing", nil, nil, true, true},
+		{"non-critical failing", nil, failed, true, false},
+		{"critical failing", failed, nil, false, false},
+		{"all failing", failed, failed, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(0, logger.NewNopLogger())
+			c.Register(Check{Name: "ffmpeg", Critical: true, Run: checkFunc(tt.critical)})
+			c.Register(Check{Name: "socketio", Run: checkFunc(tt.nonCritical)})
+
+			report := c.Evaluate(context.Background())
+			if report.Healthy != tt.healthy || report.Ready != tt.ready {
+				t.Errorf("healthy %v, ready %v, want %v, %v", report.Healthy, report.Ready, tt.healthy, tt.ready)
+			}
+			if c.Ready() != tt.ready {
+				t.Errorf("Ready() = %v, want %v", c.Ready(), tt.ready)
+			}
+
+			result := report.Checks["socketio"]
+			if result.Critical || (result.Status == StatusFail) != (tt.nonCritical != nil) {
+				t.Errorf("unexpected socketio result: %+v", result)
+			}
+			if tt.nonCritical != nil && result.Error != "failed" {
+				t.Errorf("socketio error = %q, want %q", result.Error, "failed")
+			}
+		})
+	}
+}
+
+func TestEvaluateKeepsSince(t *testing.T) {
+	var err error
+	c := NewChecker(0, logger.NewNopLogger())
+	c.Register(Check{Name: "disk", Run: func(ctx context.Context) error { return err }})
+
+	first := c.Evaluate(context.Background())
+	second := c.Evaluate(context.Background())
+	if !second.Checks["disk"].Since.Equal(first.Checks["disk"].Since) {
+		t.Error("since changed although the status did not")
+	}
+
+	err = errors.New("low on space")
+	third := c.Evaluate(context.Background())
+	if !third.Checks["disk"].Since.Equal(third.CheckedAt) {
+		t.Error("since not reset when the status changed")
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	failed := errors.New("failed")
+
+	tests := []struct {
+		name        string
+		evaluate    bool
+		critical    error
+		nonCritical error
+		liveness    int
+		readiness   int
+	}{
+		{"not evaluated yet", false, nil, nil, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"all passing", true, nil, nil, http.StatusOK, http.StatusOK},
+		{"non-critical failing", true, nil, failed, http.StatusOK, http.StatusServiceUnavailable},
+		{"critical failing", true, failed, nil, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(0, logger.NewNopLogger())
+			c.Register(Check{Name: "ffmpeg", Critical: true, Run: checkFunc(tt.critical)})
+			c.Register(Check{Name: "socketio", Run: checkFunc(tt.nonCritical)})
+			if tt.evaluate {
+				c.Evaluate(context.Background())
+			}
+
+			for _, h := range []struct {
+				path    string
+				handler http.Handler
+				want    int
+			}{
+				{"/healthz", c.LivenessHandler(), tt.liveness},
+				{"/readyz", c.ReadinessHandler(), tt.readiness},
+			} {
+				rec := httptest.NewRecorder()
+				h.handler.ServeHTTP(rec, httptest.NewRequest("GET", h.path, nil))
+
+				if rec.Code != h.want {
+					t.Errorf("%s responded %d, want %d", h.path, rec.Code, h.want)
+				}
+				if got := rec.Header().Get("Content-Type"); got != "application/json" {
+					t.Errorf("%s content type = %q", h.path, got)
+				}
+				var report Report
+				if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+					t.Errorf("%s body is not a report: %v", h.path, err)
+				}
+				if tt.evaluate && len(report.Checks) != 2 {
+					t.Errorf("%s reported %d checks, want 2", h.path, len(report.Checks))
+				}
+			}
+		})
+	}
+}
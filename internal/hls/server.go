@@ -0,0 +1,191 @@
+package hls
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+)
+
+// Camera is a camera served by the HLS server
+type Camera struct {
+	ID     string
+	Name   string
+	Status string
+	Dir    string // Directory holding index.m3u8 and its segments
+}
+
+// CameraLister returns the cameras to serve
+type CameraLister func() []Camera
+
+// contentTypes maps the files FFmpeg writes for HLS to their content type
+var contentTypes = map[string]string{
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+	".m4s":  "video/iso.segment",
+	".mp4":  "video/mp4",
+}
+
+// Server serves the cameras' HLS playlists and segments for viewing on the
+// local network
+type Server struct {
+	config  config.HLSConfig
+	cameras CameraLister
+	logger  logger.Logger
+}
+
+// NewServer creates a new HLS server
+func NewServer(cfg config.HLSConfig, cameras CameraLister, log logger.Logger) *Server {
+	return &Server{
+		config:  cfg,
+		cameras: cameras,
+		logger:  log,
+	}
+}
+
+// Handler returns the handler serving the index page below /hls/, a player
+// page per camera and the files of each camera's playlist
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hls/{$}", s.serveIndex)
+	mux.HandleFunc("GET /hls/{camera}/{$}", s.servePlayer)
+	mux.HandleFunc("GET /hls/{camera}/{file}", s.serveFile)
+	return s.authenticate(mux)
+}
+
+// authenticate requires the configured basic auth credentials
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.config.Username == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(s.config.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(s.config.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="cctv-agent", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// camera returns the served camera with the given ID
+func (s *Server) camera(id string) (Camera, bool) {
+	for _, camera := range s.cameras() {
+		if camera.ID == id {
+			return camera, true
+		}
+	}
+	return Camera{}, false
+}
+
+// serveIndex lists the cameras with their stream status
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	cameras := s.cameras()
+	sort.Slice(cameras, func(i, j int) bool { return cameras[i].ID < cameras[j].ID })
+
+	s.render(w, indexTemplate, cameras)
+}
+
+// servePlayer serves a page playing a camera's playlist
+func (s *Server) servePlayer(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r.PathValue("camera"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.render(w, playerTemplate, camera)
+}
+
+// serveFile serves a playlist or segment from a camera's HLS directory
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	camera, ok := s.camera(r.PathValue("camera"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	name := r.PathValue("file")
+	contentType, ok := contentTypes[filepath.Ext(name)]
+	if !ok || name != filepath.Base(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(camera.Dir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if filepath.Ext(name) == ".m3u8" {
+		// Playlists are rewritten with every segment
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, name, info.ModTime(), file)
+}
+
+// render writes an HTML page
+func (s *Server) render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := tmpl.Execute(w, data); err != nil {
+		s.logger.Debug("Failed to render HLS page", "error", err)
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Cameras</title>
+</head>
+<body>
+<h1>Cameras</h1>
+<table>
+<tr><th>Camera</th><th>Status</th><th>Playlist</th></tr>
+{{range .}}<tr>
+<td><a href="{{.ID}}/">{{if .Name}}{{.Name}}{{else}}{{.ID}}{{end}}</a></td>
+<td>{{.Status}}</td>
+<td><a href="{{.ID}}/index.m3u8">index.m3u8</a></td>
+</tr>
+{{else}}<tr><td colspan="3">No cameras</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var playerTemplate = template.Must(template.New("player").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Name}}{{.Name}}{{else}}{{.ID}}{{end}}</title>
+</head>
+<body>
+<p><a href="../">All cameras</a></p>
+<h1>{{if .Name}}{{.Name}}{{else}}{{.ID}}{{end}}</h1>
+<p>Status: {{.Status}}</p>
+<video src="index.m3u8" controls autoplay muted playsinline style="max-width: 100%;"></video>
+<p>If the video does not play, open <a href="index.m3u8">index.m3u8</a> in a player such as VLC.</p>
+</body>
+</html>
+`))
@@ -20,12 +20,14 @@ type Logger interface {
 	Error(msg string, keysAndValues ...interface{})
 	Fatal(msg string, keysAndValues ...interface{})
 	With(keysAndValues ...interface{}) Logger
+	SetLevel(level string)
 	Sync() error
 }
 
 // zapLogger wraps zap.SugaredLogger
 type zapLogger struct {
 	sugar *zap.SugaredLogger
+	level zap.AtomicLevel
 }
 
 // NewLogger creates a new logger instance with default settings
@@ -48,20 +50,8 @@ func NewLogger(level string) Logger {
 
 // NewLoggerWithConfig creates a new logger instance with custom configuration
 func NewLoggerWithConfig(cfg *config.LoggerConfig) Logger {
-	// Parse log level
-	var zapLevel zapcore.Level
-	switch strings.ToLower(cfg.Level) {
-	case "debug":
-		zapLevel = zapcore.DebugLevel
-	case "info":
-		zapLevel = zapcore.InfoLevel
-	case "warn":
-		zapLevel = zapcore.WarnLevel
-	case "error":
-		zapLevel = zapcore.ErrorLevel
-	default:
-		zapLevel = zapcore.InfoLevel
-	}
+	// Parse log level; cores share it so it can be changed at runtime
+	zapLevel := zap.NewAtomicLevelAt(parseLogLevel(cfg.Level))
 	
 	// Create encoder configs
 	jsonEncoderConfig := zapcore.EncoderConfig{
@@ -151,18 +141,21 @@ func NewLoggerWithConfig(cfg *config.LoggerConfig) Logger {
 	
 	return &zapLogger{
 		sugar: logger.Sugar(),
+		level: zapLevel,
 	}
 }
 
 // NewDevelopmentLogger creates a development logger
 func NewDevelopmentLogger() Logger {
-	logger, err := zap.NewDevelopment()
+	config := zap.NewDevelopmentConfig()
+	logger, err := config.Build()
 	if err != nil {
 		panic(err)
 	}
 	
 	return &zapLogger{
 		sugar: logger.Sugar(),
+		level: config.Level,
 	}
 }
 
@@ -192,6 +185,7 @@ func NewFileLogger(level, filepath string) Logger {
 	
 	return &zapLogger{
 		sugar: logger.Sugar(),
+		level: config.Level,
 	}
 }
 
@@ -225,9 +219,15 @@ func (l *zapLogger) Fatal(msg string, keysAndValues ...interface{}) {
 func (l *zapLogger) With(keysAndValues ...interface{}) Logger {
 	return &zapLogger{
 		sugar: l.sugar.With(keysAndValues...),
+		level: l.level,
 	}
 }
 
+// SetLevel changes the level of the logger and all loggers derived from it
+func (l *zapLogger) SetLevel(level string) {
+	l.level.SetLevel(parseLogLevel(level))
+}
+
 // Sync flushes any buffered log entries
 func (l *zapLogger) Sync() error {
 	return l.sugar.Sync()
@@ -265,4 +265,5 @@ func (n *NopLogger) Warn(msg string, keysAndValues ...interface{})   {}
 func (n *NopLogger) Error(msg string, keysAndValues ...interface{})  {}
 func (n *NopLogger) Fatal(msg string, keysAndValues ...interface{})  {}
 func (n *NopLogger) With(keysAndValues ...interface{}) Logger        { return n }
+func (n *NopLogger) SetLevel(level string)                           {}
 func (n *NopLogger) Sync() error                                     { return nil }
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the Prometheus text exposition format content type
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types
+const (
+	typeGauge   = "gauge"
+	typeCounter = "counter"
+)
+
+// Collector adds its current metrics to a set on every scrape
+type Collector func(s *Set)
+
+// Registry holds the collectors whose metrics are exposed
+type Registry struct {
+	collectors []Collector
+	mu         sync.RWMutex
+}
+
+// Set is the collection of metric families gathered during a scrape
+type Set struct {
+	families map[string]*family
+	order    []string
+}
+
+// family is a named group of samples sharing help text and type
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+// sample is a single labelled value
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// NewRegistry creates a new metrics registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector to the registry
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Gather runs all collectors and returns the resulting metrics
+func (r *Registry) Gather() *Set {
+	r.mu.RLock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.RUnlock()
+
+	s := &Set{families: make(map[string]*family)}
+	for _, collect := range collectors {
+		collect(s)
+	}
+	return s
+}
+
+// Handler returns an HTTP handler serving the metrics in the Prometheus
+// text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s := r.Gather()
+		w.Header().Set("Content-Type", contentType)
+		s.WriteTo(w)
+	})
+}
+
+// Gauge adds a gauge sample. Labels are given as alternating name and
+// value pairs.
+func (s *Set) Gauge(name, help string, value float64, labels ...string) {
+	s.add(typeGauge, name, help, value, labels)
+}
+
+// Counter adds a counter sample. Labels are given as alternating name and
+// value pairs.
+func (s *Set) Counter(name, help string, value float64, labels ...string) {
+	s.add(typeCounter, name, help, value, labels)
+}
+
+// add appends a sample to its family, creating the family on first use
+func (s *Set) add(typ, name, help string, value float64, labels []string) {
+	f, exists := s.families[name]
+	if !exists {
+		f = &family{name: name, help: help, typ: typ}
+		s.families[name] = f
+		s.order = append(s.order, name)
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (s *Set) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, name := range s.order {
+		f := s.families[name]
+		bw.WriteString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+		bw.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+		for _, smp := range f.samples {
+			bw.WriteString(f.name)
+			writeLabels(bw, smp.labels)
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(smp.value, 'g', -1, 64))
+			bw.WriteByte('\n')
+		}
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// writeLabels writes a label set; a trailing name without a value is ignored
+func writeLabels(bw *bufio.Writer, labels []string) {
+	if len(labels) < 2 {
+		return
+	}
+	bw.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		bw.WriteString(labels[i])
+		bw.WriteString(`="`)
+		bw.WriteString(escapeLabelValue(labels[i+1]))
+		bw.WriteByte('"')
+	}
+	bw.WriteByte('}')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp escapes help text
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// escapeLabelValue escapes a label value
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// BoolValue returns 1 for true and 0 for false
+func BoolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
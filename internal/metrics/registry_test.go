@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	r.Register(func(s *Set) {
+		s.Gauge("cctv_stream_up", "Whether the stream is connected", 1, "camera_id", "gate", "camera_name", "Gate")
+		s.Counter("cctv_stream_restarts_total", "Stream restarts", 3, "camera_id", "gate")
+	})
+	r.Register(func(s *Set) {
+		s.Gauge("cctv_stream_up", "Whether the stream is connected", 0, "camera_id", "yard", "camera_name", "Yard")
+		s.Gauge("cctv_cpu_usage_percent", "CPU usage\nin percent", 12.5)
+	})
+
+	want := `# HELP cctv_stream_up Whether the stream is connected
+# TYPE cctv_stream_up gauge
+cctv_stream_up{camera_id="gate",camera_name="Gate"} 1
+cctv_stream_up{camera_id="yard",camera_name="Yard"} 0
+# HELP cctv_stream_restarts_total Stream restarts
+# TYPE cctv_stream_restarts_total counter
+cctv_stream_restarts_total{camera_id="gate"} 3
+# HELP cctv_cpu_usage_percent CPU usage\nin percent
+# TYPE cctv_cpu_usage_percent gauge
+cctv_cpu_usage_percent 12.5
+`
+
+	// Families and labels keep the order they were added in on every scrape
+	for i := 0; i < 3; i++ {
+		var b strings.Builder
+		n, err := r.Gather().WriteTo(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want {
+			t.Fatalf("scrape %d wrote\n%s\nwant\n%s", i, b.String(), want)
+		}
+		if n != int64(b.Len()) {
+			t.Errorf("WriteTo returned %d, wrote %d bytes", n, b.Len())
+		}
+	}
+}
+
+func TestWriteToEscapesLabelValues(t *testing.T) {
+	s := &Set{families: make(map[string]*family)}
+	s.Gauge("cctv_stream_up", `Help with \ and "quotes"`, 1, "camera_name", "Gate \"north\"\nC:\\cams", "dangling")
+
+	var b strings.Builder
+	if _, err := s.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# HELP cctv_stream_up Help with \\ and "quotes"
+# TYPE cctv_stream_up gauge
+cctv_stream_up{camera_name="Gate \"north\"\nC:\\cams"} 1
+`
+	if b.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Register(func(s *Set) {
+		s.Counter("cctv_commands_total", "Commands handled", 2, "type", "ptz")
+	})
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("content type = %q, want %q", got, contentType)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "cctv_commands_total{type=\"ptz\"} 2\n") {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+}
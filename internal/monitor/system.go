@@ -27,6 +27,15 @@ type SystemStats struct {
 	Network     NetworkStats
 }
 
+// DiskUsage represents the usage of a filesystem
+type DiskUsage struct {
+	Path        string
+	Total       uint64
+	Used        uint64
+	Free        uint64
+	UsedPercent float64
+}
+
 // NetworkStats represents network statistics
 type NetworkStats struct {
 	BytesSent       uint64
@@ -154,6 +163,22 @@ func (m *SystemMonitor) GetDiskUsage() (float64, error) {
 	return diskInfo.UsedPercent, nil
 }
 
+// GetPathDiskUsage returns the usage of the filesystem holding path
+func (m *SystemMonitor) GetPathDiskUsage(path string) (*DiskUsage, error) {
+	diskInfo, err := disk.Usage(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskUsage{
+		Path:        path,
+		Total:       diskInfo.Total,
+		Used:        diskInfo.Used,
+		Free:        diskInfo.Free,
+		UsedPercent: diskInfo.UsedPercent,
+	}, nil
+}
+
 // GetNetworkStats returns network statistics
 func (m *SystemMonitor) GetNetworkStats() (*NetworkStats, error) {
 	netStats, err := net.IOCounters(false)
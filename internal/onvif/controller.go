@@ -2,10 +2,31 @@ package onvif
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/cctv-agent/config"
 	"github.com/cctv-agent/internal/logger"
 	"github.com/use-go/onvif"
+	onvifdevice "github.com/use-go/onvif/device"
+	"github.com/use-go/onvif/media"
+	"github.com/use-go/onvif/ptz"
+	"github.com/use-go/onvif/xsd"
+	onvifxsd "github.com/use-go/onvif/xsd/onvif"
+)
+
+// requestTimeout bounds every request made to an ONVIF device
+const requestTimeout = 10 * time.Second
+
+// PTZ coordinate spaces used for movement requests
+const (
+	panTiltVelocitySpace = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/VelocityGenericSpace"
+	zoomVelocitySpace    = "http://www.onvif.org/ver10/tptz/ZoomSpaces/VelocityGenericSpace"
+	panTiltSpeedSpace    = "http://www.onvif.org/ver10/tptz/PanTiltSpaces/GenericSpeedSpace"
+	zoomSpeedSpace       = "http://www.onvif.org/ver10/tptz/ZoomSpaces/ZoomGenericSpeedSpace"
 )
 
 // Controller manages ONVIF devices
@@ -17,11 +38,93 @@ type Controller struct {
 
 // Device represents an ONVIF device
 type Device struct {
-	ID       string
-	Address  string
-	Username string
-	Password string
-	device   *onvif.Device
+	ID           string
+	Address      string
+	Username     string
+	Password     string
+	ProfileToken string
+	Info         DeviceInformation
+	streams      *MediaStreams
+	device       *onvif.Device
+}
+
+// DeviceInformation is the body of a GetDeviceInformation response
+type DeviceInformation struct {
+	Manufacturer    string `xml:"Manufacturer"`
+	Model           string `xml:"Model"`
+	FirmwareVersion string `xml:"FirmwareVersion"`
+	SerialNumber    string `xml:"SerialNumber"`
+	HardwareID      string `xml:"HardwareId"`
+}
+
+// endpointTransport rewrites device service requests to the configured
+// ONVIF endpoint
+type endpointTransport struct {
+	endpoint config.ONVIFEndpoint
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *endpointTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == t.endpoint.HostPort() && req.URL.Path == config.DefaultONVIFPath {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = t.endpoint.Scheme
+		req.URL.Path = t.endpoint.Path
+	}
+	return t.base.RoundTrip(req)
+}
+
+// StreamProfile describes a media profile selected for streaming
+type StreamProfile struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Encoding string `json:"encoding"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FPS      int    `json:"fps"`
+	Bitrate  int    `json:"bitrate"` // kbit/s
+	URI      string `json:"-"`
+}
+
+// MediaStreams holds the stream profiles resolved for a device
+type MediaStreams struct {
+	Main StreamProfile
+	Sub  *StreamProfile
+}
+
+// mediaProfile is the subset of an ONVIF media profile used by the controller
+type mediaProfile struct {
+	Token                     string `xml:"token,attr"`
+	Name                      string `xml:"Name"`
+	VideoEncoderConfiguration *struct {
+		Encoding   string `xml:"Encoding"`
+		Resolution struct {
+			Width  int `xml:"Width"`
+			Height int `xml:"Height"`
+		} `xml:"Resolution"`
+		RateControl struct {
+			FrameRateLimit int `xml:"FrameRateLimit"`
+			BitrateLimit   int `xml:"BitrateLimit"`
+		} `xml:"RateControl"`
+	} `xml:"VideoEncoderConfiguration"`
+	PTZConfiguration *struct{} `xml:"PTZConfiguration"`
+}
+
+// getStreamUriResponse is the body of a GetStreamUri response
+type getStreamUriResponse struct {
+	MediaUri struct {
+		Uri string `xml:"Uri"`
+	} `xml:"MediaUri"`
+}
+
+// getProfilesResponse is the body of a GetProfiles response
+type getProfilesResponse struct {
+	Profiles []mediaProfile `xml:"Profiles"`
+}
+
+// setPresetResponse is the body of a SetPreset response
+type setPresetResponse struct {
+	PresetToken string `xml:"PresetToken"`
 }
 
 // PTZMovement represents PTZ movement parameters
@@ -46,44 +149,135 @@ func (c *Controller) Initialize() error {
 	return nil
 }
 
-// Connect connects to an ONVIF device
-func (c *Controller) Connect(deviceID, address, username, password string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
+// Connect connects to an ONVIF device and verifies that it answers
+// GetDeviceInformation before accepting it
+func (c *Controller) Connect(deviceID string, endpoint config.ONVIFEndpoint, username, password string) error {
 	// Check if already connected
-	if _, exists := c.devices[deviceID]; exists {
+	if c.IsConnected(deviceID) {
 		return fmt.Errorf("device %s already connected", deviceID)
 	}
 
+	// The library always addresses http://<xaddr>/onvif/device_service, so
+	// requests are redirected to the configured scheme and path
+	transport := &endpointTransport{
+		endpoint: endpoint,
+		base:     http.DefaultTransport,
+	}
+
 	// Create ONVIF device
 	device, err := onvif.NewDevice(onvif.DeviceParams{
-		Xaddr:    fmt.Sprintf("http://%s/onvif/device_service", address),
+		Xaddr:    endpoint.HostPort(),
 		Username: username,
 		Password: password,
+		HttpClient: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: transport,
+		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create ONVIF device: %w", err)
+		return fmt.Errorf("failed to create ONVIF device at %s: %w", endpoint.URL(), err)
 	}
 
-	c.logger.Info("Connected to ONVIF device",
-		"device_id", deviceID,
-		"address", address,
-	)
-
 	// Create device entry
 	dev := &Device{
 		ID:       deviceID,
-		Address:  address,
+		Address:  endpoint.URL(),
 		Username: username,
 		Password: password,
 		device:   device,
 	}
 
+	// Verify the device is reachable and accepts our credentials
+	if err := dev.call(onvifdevice.GetDeviceInformation{}, &dev.Info); err != nil {
+		return fmt.Errorf("ONVIF device at %s is not reachable: %w", endpoint.URL(), err)
+	}
+
+	// Resolve the media profile used for PTZ requests
+	profiles, err := dev.getProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to get media profiles: %w", err)
+	}
+	dev.ProfileToken = selectPTZProfile(profiles)
+	if dev.ProfileToken == "" {
+		return fmt.Errorf("device %s has no media profiles", deviceID)
+	}
+
+	c.logger.Info("Connected to ONVIF device",
+		"device_id", deviceID,
+		"address", dev.Address,
+		"manufacturer", dev.Info.Manufacturer,
+		"model", dev.Info.Model,
+		"firmware", dev.Info.FirmwareVersion,
+		"profile_token", dev.ProfileToken,
+	)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.devices[deviceID]; exists {
+		return fmt.Errorf("device %s already connected", deviceID)
+	}
 	c.devices[deviceID] = dev
 	return nil
 }
 
+// getProfiles fetches the media profiles of the device
+func (d *Device) getProfiles() ([]mediaProfile, error) {
+	var resp getProfilesResponse
+	if err := d.call(media.GetProfiles{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Profiles, nil
+}
+
+// call invokes an ONVIF method and decodes the response body into response,
+// which may be nil when no data is expected
+func (d *Device) call(method interface{}, response interface{}) error {
+	resp, err := d.device.CallMethod(method)
+	if err != nil {
+		return fmt.Errorf("ONVIF request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return decodeResponse(resp, response)
+}
+
+// selectPTZProfile returns the token of the first profile with a PTZ
+// configuration, falling back to the first profile
+func selectPTZProfile(profiles []mediaProfile) string {
+	for _, profile := range profiles {
+		if profile.PTZConfiguration != nil && profile.Token != "" {
+			return profile.Token
+		}
+	}
+	if len(profiles) > 0 {
+		return profiles[0].Token
+	}
+	return ""
+}
+
+// getDevice returns a connected device
+func (c *Controller) getDevice(deviceID string) (*Device, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	dev, exists := c.devices[deviceID]
+	if !exists {
+		return nil, fmt.Errorf("device %s not found", deviceID)
+	}
+	return dev, nil
+}
+
+// clampVelocity limits a velocity to the generic [-1, 1] range
+func clampVelocity(v float32) float64 {
+	if v > 1 {
+		return 1
+	}
+	if v < -1 {
+		return -1
+	}
+	return float64(v)
+}
+
 // Disconnect disconnects from an ONVIF device
 func (c *Controller) Disconnect(deviceID string) error {
 	c.mu.Lock()
@@ -98,17 +292,19 @@ func (c *Controller) Disconnect(deviceID string) error {
 	return nil
 }
 
-// Move performs continuous PTZ movement
+// Move performs continuous PTZ movement. Pan, tilt and zoom are velocities
+// in the range [-1, 1], scaled by Speed when it is set.
 func (c *Controller) Move(deviceID string, movement PTZMovement) error {
-	c.mu.RLock()
-	dev, exists := c.devices[deviceID]
-	c.mu.RUnlock()
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
+	}
 
-	if !exists {
-		return fmt.Errorf("device %s not found", deviceID)
+	speed := movement.Speed
+	if speed <= 0 {
+		speed = 1
 	}
 
-	// Log the PTZ command (simplified implementation)
 	c.logger.Info("PTZ Move command",
 		"device_id", deviceID,
 		"pan", movement.Pan,
@@ -117,39 +313,53 @@ func (c *Controller) Move(deviceID string, movement PTZMovement) error {
 		"speed", movement.Speed,
 	)
 
-	// In a production implementation, you would use the ONVIF device
-	// to send actual PTZ commands using the appropriate ONVIF methods
-	_ = dev.device
+	req := ptz.ContinuousMove{
+		ProfileToken: onvifxsd.ReferenceToken(dev.ProfileToken),
+		Velocity: onvifxsd.PTZSpeed{
+			PanTilt: onvifxsd.Vector2D{
+				X:     clampVelocity(movement.Pan * speed),
+				Y:     clampVelocity(movement.Tilt * speed),
+				Space: xsd.AnyURI(panTiltVelocitySpace),
+			},
+			Zoom: onvifxsd.Vector1D{
+				X:     clampVelocity(movement.Zoom * speed),
+				Space: xsd.AnyURI(zoomVelocitySpace),
+			},
+		},
+	}
+	if err := dev.call(req, nil); err != nil {
+		return fmt.Errorf("continuous move failed: %w", err)
+	}
 
 	return nil
 }
 
 // Stop stops PTZ movement
 func (c *Controller) Stop(deviceID string) error {
-	c.mu.RLock()
-	dev, exists := c.devices[deviceID]
-	c.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("device %s not found", deviceID)
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
 	}
 
 	c.logger.Info("PTZ Stop command", "device_id", deviceID)
-	
-	// In a production implementation, you would send stop command
-	_ = dev.device
+
+	req := ptz.Stop{
+		ProfileToken: onvifxsd.ReferenceToken(dev.ProfileToken),
+		PanTilt:      xsd.Boolean(true),
+		Zoom:         xsd.Boolean(true),
+	}
+	if err := dev.call(req, nil); err != nil {
+		return fmt.Errorf("stop failed: %w", err)
+	}
 
 	return nil
 }
 
 // GoToPreset moves to a preset position
 func (c *Controller) GoToPreset(deviceID string, presetToken string) error {
-	c.mu.RLock()
-	dev, exists := c.devices[deviceID]
-	c.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("device %s not found", deviceID)
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
 	}
 
 	c.logger.Info("PTZ GoToPreset command",
@@ -157,45 +367,55 @@ func (c *Controller) GoToPreset(deviceID string, presetToken string) error {
 		"preset", presetToken,
 	)
 
-	// In a production implementation, you would send goto preset command
-	_ = dev.device
+	req := ptz.GotoPreset{
+		ProfileToken: onvifxsd.ReferenceToken(dev.ProfileToken),
+		PresetToken:  onvifxsd.ReferenceToken(presetToken),
+		Speed: onvifxsd.PTZSpeed{
+			PanTilt: onvifxsd.Vector2D{X: 1, Y: 1, Space: xsd.AnyURI(panTiltSpeedSpace)},
+			Zoom:    onvifxsd.Vector1D{X: 1, Space: xsd.AnyURI(zoomSpeedSpace)},
+		},
+	}
+	if err := dev.call(req, nil); err != nil {
+		return fmt.Errorf("goto preset failed: %w", err)
+	}
 
 	return nil
 }
 
-// SetPreset sets a preset position
+// SetPreset stores the current position as a preset and returns the token
+// assigned by the device
 func (c *Controller) SetPreset(deviceID string, presetName string) (string, error) {
-	c.mu.RLock()
-	dev, exists := c.devices[deviceID]
-	c.mu.RUnlock()
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return "", err
+	}
 
-	if !exists {
-		return "", fmt.Errorf("device %s not found", deviceID)
+	req := ptz.SetPreset{
+		ProfileToken: onvifxsd.ReferenceToken(dev.ProfileToken),
+		PresetName:   xsd.String(presetName),
+	}
+	var resp setPresetResponse
+	if err := dev.call(req, &resp); err != nil {
+		return "", fmt.Errorf("set preset failed: %w", err)
+	}
+	if resp.PresetToken == "" {
+		return "", fmt.Errorf("set preset failed: device returned no preset token")
 	}
 
-	// Generate a preset token
-	presetToken := fmt.Sprintf("preset_%s", presetName)
-	
 	c.logger.Info("PTZ SetPreset command",
 		"device_id", deviceID,
 		"preset_name", presetName,
-		"preset_token", presetToken,
+		"preset_token", resp.PresetToken,
 	)
 
-	// In a production implementation, you would send set preset command
-	_ = dev.device
-
-	return presetToken, nil
+	return resp.PresetToken, nil
 }
 
 // RemovePreset removes a preset position
 func (c *Controller) RemovePreset(deviceID string, presetToken string) error {
-	c.mu.RLock()
-	dev, exists := c.devices[deviceID]
-	c.mu.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("device %s not found", deviceID)
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return err
 	}
 
 	c.logger.Info("PTZ RemovePreset command",
@@ -203,12 +423,174 @@ func (c *Controller) RemovePreset(deviceID string, presetToken string) error {
 		"preset", presetToken,
 	)
 
-	// In a production implementation, you would send remove preset command
-	_ = dev.device
+	req := ptz.RemovePreset{
+		ProfileToken: onvifxsd.ReferenceToken(dev.ProfileToken),
+		PresetToken:  onvifxsd.ReferenceToken(presetToken),
+	}
+	if err := dev.call(req, nil); err != nil {
+		return fmt.Errorf("remove preset failed: %w", err)
+	}
 
 	return nil
 }
 
+// ResolveStreams looks up the RTSP URIs of the device's media profiles. The
+// profile with the highest resolution is used as the main stream, unless
+// preferredToken names another one, and the next best as the sub stream.
+// Credentials of the device are added to the returned URIs.
+func (c *Controller) ResolveStreams(deviceID, preferredToken string) (*MediaStreams, error) {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := dev.getProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media profiles: %w", err)
+	}
+
+	candidates := make([]StreamProfile, 0, len(profiles))
+	for _, profile := range profiles {
+		if profile.VideoEncoderConfiguration == nil || profile.Token == "" {
+			continue
+		}
+		vec := profile.VideoEncoderConfiguration
+		candidates = append(candidates, StreamProfile{
+			Token:    profile.Token,
+			Name:     profile.Name,
+			Encoding: vec.Encoding,
+			Width:    vec.Resolution.Width,
+			Height:   vec.Resolution.Height,
+			FPS:      vec.RateControl.FrameRateLimit,
+			Bitrate:  vec.RateControl.BitrateLimit,
+		})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("device %s has no video profiles", deviceID)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Token == preferredToken {
+			return true
+		}
+		if candidates[j].Token == preferredToken {
+			return false
+		}
+		return candidates[i].Width*candidates[i].Height > candidates[j].Width*candidates[j].Height
+	})
+
+	streams := &MediaStreams{Main: candidates[0]}
+	if streams.Main.URI, err = dev.getStreamURI(streams.Main.Token); err != nil {
+		return nil, fmt.Errorf("failed to get stream URI for profile %s: %w", streams.Main.Token, err)
+	}
+
+	if len(candidates) > 1 {
+		sub := candidates[1]
+		if sub.URI, err = dev.getStreamURI(sub.Token); err != nil {
+			c.logger.Warn("Failed to get sub stream URI",
+				"device_id", deviceID,
+				"profile_token", sub.Token,
+				"error", err)
+		} else {
+			streams.Sub = &sub
+		}
+	}
+
+	c.mu.Lock()
+	dev.streams = streams
+	c.mu.Unlock()
+
+	c.logger.Info("Resolved ONVIF stream profiles",
+		"device_id", deviceID,
+		"profile", streams.Main.Name,
+		"encoding", streams.Main.Encoding,
+		"resolution", fmt.Sprintf("%dx%d", streams.Main.Width, streams.Main.Height),
+		"fps", streams.Main.FPS,
+	)
+
+	return streams, nil
+}
+
+// GetStreamProfile returns the main stream profile resolved for a device
+func (c *Controller) GetStreamProfile(deviceID string) (StreamProfile, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	dev, exists := c.devices[deviceID]
+	if !exists || dev.streams == nil {
+		return StreamProfile{}, false
+	}
+	return dev.streams.Main, true
+}
+
+// getSnapshotUriResponse is the body of a GetSnapshotUri response
+type getSnapshotUriResponse struct {
+	MediaUri struct {
+		Uri string `xml:"Uri"`
+	} `xml:"MediaUri"`
+}
+
+// GetSnapshotURI looks up the HTTP URI serving JPEG snapshots of the
+// device's streamed profile, or of its PTZ profile when no stream was
+// resolved
+func (c *Controller) GetSnapshotURI(deviceID string) (string, error) {
+	dev, err := c.getDevice(deviceID)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	token := dev.ProfileToken
+	if dev.streams != nil {
+		token = dev.streams.Main.Token
+	}
+	c.mu.RUnlock()
+
+	var resp getSnapshotUriResponse
+	if err := dev.call(media.GetSnapshotUri{ProfileToken: onvifxsd.ReferenceToken(token)}, &resp); err != nil {
+		return "", fmt.Errorf("failed to get snapshot URI for profile %s: %w", token, err)
+	}
+	if resp.MediaUri.Uri == "" {
+		return "", fmt.Errorf("device returned an empty snapshot URI")
+	}
+	return resp.MediaUri.Uri, nil
+}
+
+// getStreamURI fetches the RTSP URI of a media profile
+func (d *Device) getStreamURI(profileToken string) (string, error) {
+	req := media.GetStreamUri{
+		StreamSetup: onvifxsd.StreamSetup{
+			Stream: onvifxsd.StreamType("RTP-Unicast"),
+			Transport: onvifxsd.Transport{
+				Protocol: onvifxsd.TransportProtocol("RTSP"),
+			},
+		},
+		ProfileToken: onvifxsd.ReferenceToken(profileToken),
+	}
+
+	var resp getStreamUriResponse
+	if err := d.call(req, &resp); err != nil {
+		return "", err
+	}
+	if resp.MediaUri.Uri == "" {
+		return "", fmt.Errorf("device returned an empty stream URI")
+	}
+
+	return withCredentials(resp.MediaUri.Uri, d.Username, d.Password)
+}
+
+// withCredentials adds the username and password to a URI that has none
+func withCredentials(rawURI, username, password string) (string, error) {
+	u, err := url.Parse(rawURI)
+	if err != nil {
+		return "", fmt.Errorf("invalid stream URI %q: %w", rawURI, err)
+	}
+	if u.User == nil && username != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	return u.String(), nil
+}
+
 // GetDeviceInfo gets information about a connected device
 func (c *Controller) GetDeviceInfo(deviceID string) (map[string]interface{}, error) {
 	c.mu.RLock()
@@ -220,9 +602,14 @@ func (c *Controller) GetDeviceInfo(deviceID string) (map[string]interface{}, err
 	}
 
 	info := map[string]interface{}{
-		"id":       dev.ID,
-		"address":  dev.Address,
-		"username": dev.Username,
+		"id":            dev.ID,
+		"address":       dev.Address,
+		"username":      dev.Username,
+		"profile_token": dev.ProfileToken,
+		"manufacturer":  dev.Info.Manufacturer,
+		"model":         dev.Info.Model,
+		"firmware":      dev.Info.FirmwareVersion,
+		"serial_number": dev.Info.SerialNumber,
 	}
 
 	return info, nil
@@ -0,0 +1,354 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+)
+
+// devicePath is the device service path of the fake device, which differs
+// from the default to exercise the endpoint rewrite
+const devicePath = "/custom/device_service"
+
+// soapRequest is a request received by the fake device
+type soapRequest struct {
+	path   string
+	action string
+	body   []byte
+}
+
+// fakeDevice is an ONVIF device answering SOAP requests over HTTP
+type fakeDevice struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []soapRequest
+	// faults maps actions to the fault subcode they are answered with
+	faults map[string]string
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	t.Helper()
+	d := &fakeDevice{faults: make(map[string]string)}
+	d.server = httptest.NewServer(http.HandlerFunc(d.serve))
+	t.Cleanup(d.server.Close)
+	return d
+}
+
+// endpoint returns the ONVIF endpoint of the fake device
+func (d *fakeDevice) endpoint(t *testing.T) config.ONVIFEndpoint {
+	t.Helper()
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(d.server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.ONVIFEndpoint{Scheme: "http", Host: host, Port: portNum, Path: devicePath}
+}
+
+// request returns the last request made for an action
+func (d *fakeDevice) request(t *testing.T, action string) soapRequest {
+	t.Helper()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := len(d.requests) - 1; i >= 0; i-- {
+		if d.requests[i].action == action {
+			return d.requests[i]
+		}
+	}
+	t.Fatalf("device received no %s request", action)
+	return soapRequest{}
+}
+
+func (d *fakeDevice) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var env struct {
+		Body struct {
+			Content struct {
+				XMLName xml.Name
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(body, &env); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := env.Body.Content.XMLName.Local
+
+	d.mu.Lock()
+	d.requests = append(d.requests, soapRequest{path: r.URL.Path, action: action, body: body})
+	fault := d.faults[action]
+	d.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	if fault != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, soapFaultTemplate, fault, "The requested token does not exist.")
+		return
+	}
+
+	var response string
+	switch action {
+	case "GetCapabilities":
+		response = fmt.Sprintf(`<tds:GetCapabilitiesResponse><tds:Capabilities>
+<tt:Device><tt:XAddr>%[1]s/onvif/device_service</tt:XAddr></tt:Device>
+<tt:Media><tt:XAddr>%[1]s/onvif/media_service</tt:XAddr></tt:Media>
+<tt:PTZ><tt:XAddr>%[1]s/onvif/ptz_service</tt:XAddr></tt:PTZ>
+</tds:Capabilities></tds:GetCapabilitiesResponse>`, d.server.URL)
+	case "GetDeviceInformation":
+		response = `<tds:GetDeviceInformationResponse>
+<tds:Manufacturer>Acme</tds:Manufacturer>
+<tds:Model>PTZ-1000</tds:Model>
+<tds:FirmwareVersion>1.2.3</tds:FirmwareVersion>
+<tds:SerialNumber>SN123</tds:SerialNumber>
+<tds:HardwareId>HW1</tds:HardwareId>
+</tds:GetDeviceInformationResponse>`
+	case "GetProfiles":
+		response = `<trt:GetProfilesResponse>
+<trt:Profiles token="profile_main"><tt:Name>Main</tt:Name></trt:Profiles>
+<trt:Profiles token="profile_ptz"><tt:Name>PTZ</tt:Name><tt:PTZConfiguration token="ptz0"/></trt:Profiles>
+</trt:GetProfilesResponse>`
+	case "SetPreset":
+		response = `<tptz:SetPresetResponse><tptz:PresetToken>7</tptz:PresetToken></tptz:SetPresetResponse>`
+	case "ContinuousMove", "Stop", "GotoPreset", "RemovePreset":
+		response = "<tptz:" + action + "Response/>"
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, soapFaultTemplate, "ter:ActionNotSupported", "")
+		return
+	}
+	fmt.Fprintf(w, soapResponseTemplate, response)
+}
+
+const soapResponseTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:tds="http://www.onvif.org/ver10/device/wsdl" xmlns:trt="http://www.onvif.org/ver10/media/wsdl" xmlns:tptz="http://www.onvif.org/ver20/ptz/wsdl" xmlns:tt="http://www.onvif.org/ver10/schema">
+<env:Body>%s</env:Body>
+</env:Envelope>`
+
+const soapFaultTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope" xmlns:ter="http://www.onvif.org/ver10/error">
+<env:Body><env:Fault>
+<env:Code><env:Value>env:Sender</env:Value><env:Subcode><env:Value>%s</env:Value></env:Subcode></env:Code>
+<env:Reason><env:Text xml:lang="en">%s</env:Text></env:Reason>
+</env:Fault></env:Body>
+</env:Envelope>`
+
+// connectFakeDevice connects a controller to a fake device as "cam1"
+func connectFakeDevice(t *testing.T) (*Controller, *fakeDevice) {
+	t.Helper()
+	d := newFakeDevice(t)
+	c := NewController(logger.NewNopLogger())
+	if err := c.Connect("cam1", d.endpoint(t), "admin", "secret"); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return c, d
+}
+
+// ptzVector is a velocity or speed vector of a PTZ request
+type ptzVector struct {
+	X     float64 `xml:"x,attr"`
+	Y     float64 `xml:"y,attr"`
+	Space string  `xml:"space,attr"`
+}
+
+func TestConnect(t *testing.T) {
+	c, d := connectFakeDevice(t)
+
+	if !c.IsConnected("cam1") {
+		t.Fatal("device is not connected")
+	}
+	if got := d.request(t, "GetDeviceInformation").path; got != devicePath {
+		t.Errorf("device service requested at %s, want %s", got, devicePath)
+	}
+
+	info, err := c.GetDeviceInfo("cam1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info["manufacturer"] != "Acme" || info["model"] != "PTZ-1000" || info["firmware"] != "1.2.3" {
+		t.Errorf("unexpected device information: %v", info)
+	}
+	// The profile with a PTZ configuration is preferred over the first one
+	if info["profile_token"] != "profile_ptz" {
+		t.Errorf("profile token = %v, want profile_ptz", info["profile_token"])
+	}
+
+	if err := c.Connect("cam1", d.endpoint(t), "admin", "secret"); err == nil {
+		t.Error("connecting a connected device succeeded")
+	}
+}
+
+func TestConnectFault(t *testing.T) {
+	d := newFakeDevice(t)
+	d.faults["GetDeviceInformation"] = "ter:NotAuthorized"
+
+	c := NewController(logger.NewNopLogger())
+	err := c.Connect("cam1", d.endpoint(t), "admin", "wrong")
+	if err == nil || !strings.Contains(err.Error(), "ter:NotAuthorized") {
+		t.Fatalf("Connect error = %v, want the device's fault", err)
+	}
+	if c.IsConnected("cam1") {
+		t.Error("device that rejected the credentials is connected")
+	}
+}
+
+func TestMove(t *testing.T) {
+	c, d := connectFakeDevice(t)
+
+	if err := c.Move("cam1", PTZMovement{Pan: 0.5, Tilt: -2, Zoom: 0.25, Speed: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := d.request(t, "ContinuousMove")
+	if req.path != "/onvif/ptz_service" {
+		t.Errorf("ContinuousMove sent to %s, want the PTZ service", req.path)
+	}
+	var move struct {
+		ProfileToken string    `xml:"Body>ContinuousMove>ProfileToken"`
+		PanTilt      ptzVector `xml:"Body>ContinuousMove>Velocity>PanTilt"`
+		Zoom         ptzVector `xml:"Body>ContinuousMove>Velocity>Zoom"`
+	}
+	if err := xml.Unmarshal(req.body, &move); err != nil {
+		t.Fatal(err)
+	}
+
+	if move.ProfileToken != "profile_ptz" {
+		t.Errorf("profile token = %q, want profile_ptz", move.ProfileToken)
+	}
+	// Velocities are scaled by the speed and clamped to [-1, 1]
+	if move.PanTilt.X != 0.25 || move.PanTilt.Y != -1 || move.Zoom.X != 0.125 {
+		t.Errorf("velocity = %+v %+v, want pan 0.25, tilt -1, zoom 0.125", move.PanTilt, move.Zoom)
+	}
+	if move.PanTilt.Space != panTiltVelocitySpace || move.Zoom.Space != zoomVelocitySpace {
+		t.Errorf("velocity spaces = %q %q", move.PanTilt.Space, move.Zoom.Space)
+	}
+}
+
+func TestStop(t *testing.T) {
+	c, d := connectFakeDevice(t)
+
+	if err := c.Stop("cam1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stop struct {
+		ProfileToken string `xml:"Body>Stop>ProfileToken"`
+		PanTilt      bool   `xml:"Body>Stop>PanTilt"`
+		Zoom         bool   `xml:"Body>Stop>Zoom"`
+	}
+	if err := xml.Unmarshal(d.request(t, "Stop").body, &stop); err != nil {
+		t.Fatal(err)
+	}
+	if stop.ProfileToken != "profile_ptz" || !stop.PanTilt || !stop.Zoom {
+		t.Errorf("unexpected Stop request: %+v", stop)
+	}
+}
+
+func TestGoToPreset(t *testing.T) {
+	c, d := connectFakeDevice(t)
+
+	if err := c.GoToPreset("cam1", "3"); err != nil {
+		t.Fatal(err)
+	}
+
+	var goTo struct {
+		ProfileToken string    `xml:"Body>GotoPreset>ProfileToken"`
+		PresetToken  string    `xml:"Body>GotoPreset>PresetToken"`
+		PanTilt      ptzVector `xml:"Body>GotoPreset>Speed>PanTilt"`
+	}
+	if err := xml.Unmarshal(d.request(t, "GotoPreset").body, &goTo); err != nil {
+		t.Fatal(err)
+	}
+	if goTo.ProfileToken != "profile_ptz" || goTo.PresetToken != "3" {
+		t.Errorf("unexpected GotoPreset request: %+v", goTo)
+	}
+	if goTo.PanTilt.X != 1 || goTo.PanTilt.Y != 1 || goTo.PanTilt.Space != panTiltSpeedSpace {
+		t.Errorf("speed = %+v, want full speed", goTo.PanTilt)
+	}
+}
+
+func TestGoToPresetFault(t *testing.T) {
+	c, d := connectFakeDevice(t)
+	d.faults["GotoPreset"] = "ter:NoToken"
+
+	err := c.GoToPreset("cam1", "99")
+	var fault *soapFault
+	if !errors.As(err, &fault) {
+		t.Fatalf("GoToPreset error = %v, want a SOAP fault", err)
+	}
+	want := "goto preset failed: SOAP fault: ter:NoToken: The requested token does not exist."
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestSetPreset(t *testing.T) {
+	c, d := connectFakeDevice(t)
+
+	token, err := c.SetPreset("cam1", "Gate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "7" {
+		t.Errorf("preset token = %q, want the token assigned by the device", token)
+	}
+
+	var set struct {
+		ProfileToken string `xml:"Body>SetPreset>ProfileToken"`
+		PresetName   string `xml:"Body>SetPreset>PresetName"`
+	}
+	if err := xml.Unmarshal(d.request(t, "SetPreset").body, &set); err != nil {
+		t.Fatal(err)
+	}
+	if set.ProfileToken != "profile_ptz" || set.PresetName != "Gate" {
+		t.Errorf("unexpected SetPreset request: %+v", set)
+	}
+}
+
+func TestRemovePreset(t *testing.T) {
+	c, d := connectFakeDevice(t)
+
+	if err := c.RemovePreset("cam1", "7"); err != nil {
+		t.Fatal(err)
+	}
+
+	var remove struct {
+		ProfileToken string `xml:"Body>RemovePreset>ProfileToken"`
+		PresetToken  string `xml:"Body>RemovePreset>PresetToken"`
+	}
+	if err := xml.Unmarshal(d.request(t, "RemovePreset").body, &remove); err != nil {
+		t.Fatal(err)
+	}
+	if remove.ProfileToken != "profile_ptz" || remove.PresetToken != "7" {
+		t.Errorf("unexpected RemovePreset request: %+v", remove)
+	}
+}
+
+func TestCommandsRequireConnection(t *testing.T) {
+	c := NewController(logger.NewNopLogger())
+	if err := c.Stop("cam1"); err == nil {
+		t.Error("Stop succeeded on an unknown device")
+	}
+	if _, err := c.SetPreset("cam1", "Gate"); err == nil {
+		t.Error("SetPreset succeeded on an unknown device")
+	}
+}
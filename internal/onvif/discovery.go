@@ -0,0 +1,228 @@
+package onvif
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// wsDiscoveryAddress is the WS-Discovery IPv4 multicast group and port
+	wsDiscoveryAddress = "239.255.255.250:3702"
+
+	// DefaultDiscoveryTimeout is how long probe responses are collected
+	DefaultDiscoveryTimeout = 3 * time.Second
+
+	// probeAttempts is how many times the probe is sent, and probeInterval
+	// the delay between attempts. WS-Discovery repeats UDP messages since
+	// multicast datagrams are easily lost.
+	probeAttempts = 3
+	probeInterval = 100 * time.Millisecond
+
+	// ONVIF scope prefixes carrying human readable device details
+	scopeName     = "onvif://www.onvif.org/name/"
+	scopeHardware = "onvif://www.onvif.org/hardware/"
+	scopeLocation = "onvif://www.onvif.org/location/"
+)
+
+// probeTemplate is a WS-Discovery Probe for ONVIF network video transmitters
+const probeTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<e:Envelope xmlns:e="http://www.w3.org/2003/05/soap-envelope" xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery" xmlns:dn="http://www.onvif.org/ver10/network/wsdl">
+<e:Header>
+<w:MessageID>%s</w:MessageID>
+<w:To e:mustUnderstand="true">urn:schemas-xmlsoap-org:ws:2005:04:discovery</w:To>
+<w:Action e:mustUnderstand="true">http://schemas.xmlsoap.org/ws/2005/04/discovery/Probe</w:Action>
+</e:Header>
+<e:Body>
+<d:Probe><d:Types>dn:NetworkVideoTransmitter</d:Types></d:Probe>
+</e:Body>
+</e:Envelope>`
+
+// DiscoveredDevice describes a device that answered a WS-Discovery probe
+type DiscoveredDevice struct {
+	EndpointReference string   `json:"endpoint_reference"`
+	XAddrs            []string `json:"xaddrs"`
+	Types             []string `json:"types,omitempty"`
+	Scopes            []string `json:"scopes,omitempty"`
+	Name              string   `json:"name,omitempty"`
+	Hardware          string   `json:"hardware,omitempty"`
+	Location          string   `json:"location,omitempty"`
+}
+
+// probeMatchEnvelope is the subset of a ProbeMatches message used here
+type probeMatchEnvelope struct {
+	Header struct {
+		RelatesTo string `xml:"RelatesTo"`
+	} `xml:"Header"`
+	Body struct {
+		ProbeMatches struct {
+			ProbeMatch []struct {
+				EndpointReference struct {
+					Address string `xml:"Address"`
+				} `xml:"EndpointReference"`
+				Types  string `xml:"Types"`
+				Scopes string `xml:"Scopes"`
+				XAddrs string `xml:"XAddrs"`
+			} `xml:"ProbeMatch"`
+		} `xml:"ProbeMatches"`
+	} `xml:"Body"`
+}
+
+// Discover probes the local network for ONVIF devices and returns those that
+// answer before the timeout expires or the context is cancelled
+func Discover(ctx context.Context, timeout time.Duration) ([]DiscoveredDevice, error) {
+	target, err := net.ResolveUDPAddr("udp4", wsDiscoveryAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve discovery address: %w", err)
+	}
+	return discover(ctx, target, timeout)
+}
+
+// discover sends probes to target and collects the matches
+func discover(ctx context.Context, target *net.UDPAddr, timeout time.Duration) ([]DiscoveredDevice, error) {
+	if timeout <= 0 {
+		timeout = DefaultDiscoveryTimeout
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open discovery socket: %w", err)
+	}
+	defer conn.Close()
+
+	messageID, err := newMessageID()
+	if err != nil {
+		return nil, err
+	}
+
+	probe := []byte(fmt.Sprintf(probeTemplate, messageID))
+	if _, err := conn.WriteToUDP(probe, target); err != nil {
+		return nil, fmt.Errorf("failed to send probe: %w", err)
+	}
+
+	// Repeats keep the message ID, so devices answering each of them are
+	// de-duplicated below
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for i := 1; i < probeAttempts; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(probeInterval):
+			}
+			conn.WriteToUDP(probe, target)
+		}
+	}()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("failed to set read deadline: %w", err)
+	}
+
+	// Unblock the read when the context is cancelled early
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	devices := make([]DiscoveredDevice, 0)
+	seen := make(map[string]bool)
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			return devices, fmt.Errorf("failed to read probe response: %w", err)
+		}
+
+		for _, device := range parseProbeMatches(buf[:n], messageID) {
+			key := device.EndpointReference
+			if key == "" {
+				key = strings.Join(device.XAddrs, " ")
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			devices = append(devices, device)
+		}
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return devices, ctx.Err()
+	}
+	return devices, nil
+}
+
+// parseProbeMatches extracts the devices from a ProbeMatches message that
+// answers the probe with the given message ID
+func parseProbeMatches(data []byte, messageID string) []DiscoveredDevice {
+	var env probeMatchEnvelope
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil
+	}
+	if relatesTo := strings.TrimSpace(env.Header.RelatesTo); relatesTo != "" && relatesTo != messageID {
+		return nil
+	}
+
+	var devices []DiscoveredDevice
+	for _, match := range env.Body.ProbeMatches.ProbeMatch {
+		xaddrs := strings.Fields(match.XAddrs)
+		if len(xaddrs) == 0 {
+			continue
+		}
+
+		device := DiscoveredDevice{
+			EndpointReference: strings.TrimSpace(match.EndpointReference.Address),
+			XAddrs:            xaddrs,
+			Types:             strings.Fields(match.Types),
+			Scopes:            strings.Fields(match.Scopes),
+		}
+		for _, scope := range device.Scopes {
+			switch {
+			case strings.HasPrefix(scope, scopeName):
+				device.Name = unescapeScope(strings.TrimPrefix(scope, scopeName))
+			case strings.HasPrefix(scope, scopeHardware):
+				device.Hardware = unescapeScope(strings.TrimPrefix(scope, scopeHardware))
+			case strings.HasPrefix(scope, scopeLocation):
+				device.Location = unescapeScope(strings.TrimPrefix(scope, scopeLocation))
+			}
+		}
+		devices = append(devices, device)
+	}
+
+	return devices
+}
+
+// unescapeScope decodes a percent-encoded scope value
+func unescapeScope(value string) string {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// newMessageID generates a random urn:uuid message ID
+func newMessageID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate message ID: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
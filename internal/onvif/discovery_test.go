@@ -0,0 +1,198 @@
+package onvif
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const probeMatchTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<e:Envelope xmlns:e="http://www.w3.org/2003/05/soap-envelope" xmlns:w="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:d="http://schemas.xmlsoap.org/ws/2005/04/discovery">
+<e:Header><w:RelatesTo>%s</w:RelatesTo></e:Header>
+<e:Body><d:ProbeMatches>%s</d:ProbeMatches></e:Body>
+</e:Envelope>`
+
+const probeMatchEntryTemplate = `<d:ProbeMatch>
+<w:EndpointReference><w:Address>%s</w:Address></w:EndpointReference>
+<d:Types>dn:NetworkVideoTransmitter</d:Types>
+<d:Scopes>%s</d:Scopes>
+<d:XAddrs>%s</d:XAddrs>
+</d:ProbeMatch>`
+
+// probeResponder answers WS-Discovery probes on a loopback UDP socket
+type probeResponder struct {
+	conn *net.UDPConn
+
+	mu         sync.Mutex
+	messageIDs []string
+}
+
+// newProbeResponder starts a responder. respond returns the messages sent
+// back for a probe with the given message ID.
+func newProbeResponder(t *testing.T, respond func(messageID string) []string) *probeResponder {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	r := &probeResponder{conn: conn}
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+
+			var probe struct {
+				MessageID string `xml:"Header>MessageID"`
+				Types     string `xml:"Body>Probe>Types"`
+			}
+			if err := xml.Unmarshal(buf[:n], &probe); err != nil || probe.Types == "" {
+				continue
+			}
+
+			r.mu.Lock()
+			r.messageIDs = append(r.messageIDs, probe.MessageID)
+			r.mu.Unlock()
+
+			for _, message := range respond(probe.MessageID) {
+				conn.WriteToUDP([]byte(message), from)
+			}
+		}
+	}()
+	return r
+}
+
+func (r *probeResponder) addr() *net.UDPAddr {
+	return r.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// probes returns the message IDs of the probes received
+func (r *probeResponder) probes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.messageIDs...)
+}
+
+func probeMatches(messageID string, entries ...string) string {
+	var matches string
+	for _, entry := range entries {
+		matches += entry
+	}
+	return fmt.Sprintf(probeMatchTemplate, messageID, matches)
+}
+
+func TestDiscover(t *testing.T) {
+	gate := fmt.Sprintf(probeMatchEntryTemplate,
+		"urn:uuid:gate",
+		"onvif://www.onvif.org/name/Gate%20Camera onvif://www.onvif.org/hardware/PTZ-1000 onvif://www.onvif.org/location/Front",
+		"http://192.0.2.10/onvif/device_service http://[2001:db8::10]/onvif/device_service")
+	yard := fmt.Sprintf(probeMatchEntryTemplate,
+		"urn:uuid:yard",
+		"onvif://www.onvif.org/name/Yard",
+		"http://192.0.2.11:8080/onvif/device_service")
+	noAddress := fmt.Sprintf(probeMatchEntryTemplate, "urn:uuid:broken", "", "")
+
+	responder := newProbeResponder(t, func(messageID string) []string {
+		return []string{
+			// Every probe is answered, and the gate camera answers twice
+			probeMatches(messageID, gate, noAddress),
+			probeMatches(messageID, gate, yard),
+			// Answers to other probes are ignored
+			probeMatches("urn:uuid:other", fmt.Sprintf(probeMatchEntryTemplate, "urn:uuid:stranger", "", "http://192.0.2.99/")),
+			"not xml",
+		}
+	})
+
+	timeout := 500 * time.Millisecond
+	start := time.Now()
+	devices, err := discover(context.Background(), responder.addr(), timeout)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []DiscoveredDevice{
+		{
+			EndpointReference: "urn:uuid:gate",
+			XAddrs:            []string{"http://192.0.2.10/onvif/device_service", "http://[2001:db8::10]/onvif/device_service"},
+			Types:             []string{"dn:NetworkVideoTransmitter"},
+			Scopes: []string{
+				"onvif://www.onvif.org/name/Gate%20Camera",
+				"onvif://www.onvif.org/hardware/PTZ-1000",
+				"onvif://www.onvif.org/location/Front",
+			},
+			Name:     "Gate Camera",
+			Hardware: "PTZ-1000",
+			Location: "Front",
+		},
+		{
+			EndpointReference: "urn:uuid:yard",
+			XAddrs:            []string{"http://192.0.2.11:8080/onvif/device_service"},
+			Types:             []string{"dn:NetworkVideoTransmitter"},
+			Scopes:            []string{"onvif://www.onvif.org/name/Yard"},
+			Name:              "Yard",
+		},
+	}
+	if !reflect.DeepEqual(devices, want) {
+		t.Errorf("discovered %+v\nwant %+v", devices, want)
+	}
+
+	// Responses are collected until the timeout expires
+	if elapsed < timeout || elapsed > timeout+time.Second {
+		t.Errorf("discovery took %v, want about %v", elapsed, timeout)
+	}
+
+	probes := responder.probes()
+	if len(probes) != probeAttempts {
+		t.Fatalf("received %d probes, want %d", len(probes), probeAttempts)
+	}
+	for _, id := range probes[1:] {
+		if id != probes[0] {
+			t.Errorf("repeated probe has message ID %s, want %s", id, probes[0])
+		}
+	}
+}
+
+func TestDiscoverNoDevices(t *testing.T) {
+	responder := newProbeResponder(t, func(string) []string { return nil })
+
+	devices, err := discover(context.Background(), responder.addr(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devices == nil || len(devices) != 0 {
+		t.Errorf("discovered %v, want an empty list", devices)
+	}
+}
+
+func TestDiscoverCancelled(t *testing.T) {
+	responder := newProbeResponder(t, func(messageID string) []string {
+		return []string{probeMatches(messageID, fmt.Sprintf(probeMatchEntryTemplate, "urn:uuid:gate", "", "http://192.0.2.10/"))}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(150*time.Millisecond, cancel)
+
+	start := time.Now()
+	devices, err := discover(ctx, responder.addr(), 5*time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled discovery took %v", elapsed)
+	}
+	// Devices found before the cancellation are still returned
+	if len(devices) != 1 || devices[0].EndpointReference != "urn:uuid:gate" {
+		t.Errorf("discovered %+v, want the gate camera", devices)
+	}
+}
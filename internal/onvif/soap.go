@@ -0,0 +1,81 @@
+package onvif
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxResponseSize bounds how much of a SOAP response is read
+const maxResponseSize = 4 << 20
+
+// soapEnvelope is the subset of a SOAP envelope needed to extract the body
+type soapEnvelope struct {
+	Body struct {
+		Fault   *soapFault `xml:"Fault"`
+		Content []byte     `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+// soapFault represents a SOAP 1.2 fault
+type soapFault struct {
+	Code struct {
+		Value   string `xml:"Value"`
+		Subcode struct {
+			Value string `xml:"Value"`
+		} `xml:"Subcode"`
+	} `xml:"Code"`
+	Reason struct {
+		Text string `xml:"Text"`
+	} `xml:"Reason"`
+}
+
+// Error implements the error interface
+func (f *soapFault) Error() string {
+	code := f.Code.Value
+	if f.Code.Subcode.Value != "" {
+		code = f.Code.Subcode.Value
+	}
+	reason := strings.TrimSpace(f.Reason.Text)
+	if reason == "" {
+		return fmt.Sprintf("SOAP fault: %s", code)
+	}
+	return fmt.Sprintf("SOAP fault: %s: %s", code, reason)
+}
+
+// decodeResponse decodes the body of a SOAP response into v. Faults are
+// returned as errors. v may be nil when the response carries no data.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var env soapEnvelope
+	if err := xml.Unmarshal(data, &env); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+		}
+		return fmt.Errorf("failed to decode SOAP envelope: %w", err)
+	}
+
+	if env.Body.Fault != nil {
+		return env.Body.Fault
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	if err := xml.Unmarshal(env.Body.Content, v); err != nil {
+		return fmt.Errorf("failed to decode SOAP body: %w", err)
+	}
+
+	return nil
+}
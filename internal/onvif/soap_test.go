@@ -0,0 +1,98 @@
+package onvif
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantErr   string
+		wantFault bool
+		wantToken string
+	}{
+		{
+			name:      "success",
+			status:    http.StatusOK,
+			body:      `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><tptz:SetPresetResponse xmlns:tptz="http://www.onvif.org/ver20/ptz/wsdl"><tptz:PresetToken>5</tptz:PresetToken></tptz:SetPresetResponse></env:Body></env:Envelope>`,
+			wantToken: "5",
+		},
+		{
+			name:      "fault with subcode",
+			status:    http.StatusInternalServerError,
+			body:      `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><env:Fault><env:Code><env:Value>env:Sender</env:Value><env:Subcode><env:Value>ter:NoProfile</env:Value></env:Subcode></env:Code><env:Reason><env:Text> No such profile </env:Text></env:Reason></env:Fault></env:Body></env:Envelope>`,
+			wantErr:   "SOAP fault: ter:NoProfile: No such profile",
+			wantFault: true,
+		},
+		{
+			name:      "fault without reason",
+			status:    http.StatusBadRequest,
+			body:      `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><env:Fault><env:Code><env:Value>env:Receiver</env:Value></env:Code></env:Fault></env:Body></env:Envelope>`,
+			wantErr:   "SOAP fault: env:Receiver",
+			wantFault: true,
+		},
+		{
+			name:    "HTTP error without envelope",
+			status:  http.StatusUnauthorized,
+			body:    "Unauthorized",
+			wantErr: "unexpected HTTP status 401",
+		},
+		{
+			name:    "HTTP error with empty body",
+			status:  http.StatusInternalServerError,
+			body:    `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body/></env:Envelope>`,
+			wantErr: "unexpected HTTP status 500",
+		},
+		{
+			name:    "malformed envelope",
+			status:  http.StatusOK,
+			body:    "<env:Envelope>",
+			wantErr: "failed to decode SOAP envelope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			var v setPresetResponse
+			err := decodeResponse(resp, &v)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if v.PresetToken != tt.wantToken {
+					t.Errorf("preset token = %q, want %q", v.PresetToken, tt.wantToken)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+			var fault *soapFault
+			if errors.As(err, &fault) != tt.wantFault {
+				t.Errorf("error %v is a fault: %v, want %v", err, !tt.wantFault, tt.wantFault)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseWithoutData(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body><tptz:StopResponse xmlns:tptz="http://www.onvif.org/ver20/ptz/wsdl"/></env:Body></env:Envelope>`)),
+	}
+	if err := decodeResponse(resp, nil); err != nil {
+		t.Fatal(err)
+	}
+}
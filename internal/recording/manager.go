@@ -0,0 +1,220 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+)
+
+// URLResolver returns the RTSP URL to record a camera from
+type URLResolver func(camera *config.CameraConfig) string
+
+// ErrNoURL is returned when a camera has no RTSP URL to record from yet
+var ErrNoURL = errors.New("camera has no RTSP URL")
+
+// Manager records cameras that have recording enabled and enforces the
+// retention policy on the recording directory
+type Manager struct {
+	config           *config.Config
+	logger           logger.Logger
+	resolve          URLResolver
+	retention        *Retention
+	retentionStarted bool
+	recorders        map[string]*runningRecorder
+	mu               sync.RWMutex
+	ctx              context.Context
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+}
+
+// runningRecorder is a recorder together with the means to stop it
+type runningRecorder struct {
+	recorder *Recorder
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewManager creates a new recording manager
+func NewManager(cfg *config.Config, resolve URLResolver, disk DiskUsageProvider, log logger.Logger) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		config:    cfg,
+		logger:    log,
+		resolve:   resolve,
+		retention: NewRetention(cfg, disk, log),
+		recorders: make(map[string]*runningRecorder),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts recording every enabled camera with recording turned on.
+// Cameras whose RTSP URL is not resolved yet are skipped, and must be added
+// once it is.
+func (m *Manager) Start() error {
+	var cameras []config.CameraConfig
+	for _, camera := range m.getConfig().GetEnabledCameras() {
+		if camera.Record {
+			cameras = append(cameras, camera)
+		}
+	}
+	if len(cameras) == 0 {
+		return nil
+	}
+
+	m.logger.Info("Starting recording manager", "dir", m.getConfig().Recording.Dir)
+
+	for _, camera := range cameras {
+		cam := camera
+		if err := m.AddCamera(&cam); err != nil {
+			if errors.Is(err, ErrNoURL) {
+				m.logger.Warn("Camera has no RTSP URL yet, recording starts once it is resolved", "camera_id", cam.ID)
+				continue
+			}
+			return err
+		}
+	}
+
+	m.logger.Info("Recording manager started", "camera_count", len(cameras))
+	return nil
+}
+
+// SetConfig replaces the configuration used for recorders started from now
+// on and for the retention policy
+func (m *Manager) SetConfig(cfg *config.Config) {
+	m.mu.Lock()
+	m.config = cfg
+	m.mu.Unlock()
+
+	m.retention.SetConfig(cfg)
+}
+
+// getConfig returns the current configuration
+func (m *Manager) getConfig() *config.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// AddCamera starts recording a camera if it has recording turned on
+func (m *Manager) AddCamera(camera *config.CameraConfig) error {
+	if !camera.Record {
+		return nil
+	}
+
+	url := m.resolve(camera)
+	if url == "" {
+		return fmt.Errorf("%w: %s", ErrNoURL, camera.ID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.recorders[camera.ID]; exists {
+		return fmt.Errorf("camera already recording: %s", camera.ID)
+	}
+
+	if err := os.MkdirAll(m.config.Recording.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	running := &runningRecorder{
+		recorder: NewRecorder(camera, m.config, url, m.logger.With("camera_id", camera.ID)),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	m.recorders[camera.ID] = running
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(running.done)
+		running.recorder.Run(ctx)
+	}()
+
+	// Enforce retention once the first camera records
+	if !m.retentionStarted {
+		m.retentionStarted = true
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.retention.Run(m.ctx)
+		}()
+	}
+
+	return nil
+}
+
+// RemoveCamera stops recording a camera and waits for its current segment
+// to be finalized. Cameras that are not recording are ignored.
+func (m *Manager) RemoveCamera(cameraID string) {
+	m.mu.Lock()
+	running, exists := m.recorders[cameraID]
+	delete(m.recorders, cameraID)
+	m.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	running.cancel()
+	<-running.done
+}
+
+// IsRecording reports whether a camera is being recorded
+func (m *Manager) IsRecording(cameraID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := m.recorders[cameraID]
+	return exists
+}
+
+// Recording returns the IDs of the cameras being recorded
+func (m *Manager) Recording() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.recorders))
+	for id := range m.recorders {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Stop stops all recordings and waits for the current segments to be
+// finalized
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// GetRecorderInfo returns a snapshot of the state of all recorders
+func (m *Manager) GetRecorderInfo() map[string]RecorderInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	info := make(map[string]RecorderInfo, len(m.recorders))
+	for id, running := range m.recorders {
+		info[id] = running.recorder.Info()
+	}
+
+	return info
+}
+
+// TakeEvictions returns the segments deleted by the retention policy since
+// the last call
+func (m *Manager) TakeEvictions() []Eviction {
+	return m.retention.TakeEvictions()
+}
+
+// GetStorageInfo returns the state of the recording directory after the
+// last retention pass
+func (m *Manager) GetStorageInfo() StorageInfo {
+	return m.retention.GetStorageInfo()
+}
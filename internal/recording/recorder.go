@@ -0,0 +1,219 @@
+package recording
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+)
+
+// stopTimeout is how long FFmpeg gets to finalize the current segment
+// after being interrupted before it is killed
+const stopTimeout = 5 * time.Second
+
+// segmentPattern names segments after their start time
+const segmentPattern = "%Y%m%d-%H%M%S"
+
+// Recorder records a single camera into fixed-length segments
+type Recorder struct {
+	camera    *config.CameraConfig
+	config    *config.Config
+	url       string
+	dir       string
+	logger    logger.Logger
+	mu        sync.RWMutex
+	recording bool
+	startTime time.Time
+	lastError error
+}
+
+// RecorderInfo is a point-in-time snapshot of a recorder's state
+type RecorderInfo struct {
+	CameraID  string
+	Recording bool
+	Since     time.Time
+	LastError string
+}
+
+// NewRecorder creates a recorder that writes the camera's segments to its
+// own directory below the recording root
+func NewRecorder(camera *config.CameraConfig, cfg *config.Config, url string, log logger.Logger) *Recorder {
+	return &Recorder{
+		camera: camera,
+		config: cfg,
+		url:    url,
+		dir:    filepath.Join(cfg.Recording.Dir, camera.ID),
+		logger: log,
+	}
+}
+
+// Run records until the context is cancelled, restarting FFmpeg after the
+// camera's retry delay whenever it exits
+func (r *Recorder) Run(ctx context.Context) {
+	for {
+		err := r.record(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			r.setLastError(err)
+			r.logger.Warn("Recording interrupted, retrying",
+				"camera_id", r.camera.ID,
+				"retry_delay", r.camera.RetryDelay,
+				"error", err)
+		}
+
+		select {
+		case <-time.After(r.camera.RetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// record runs a single FFmpeg recording process
+func (r *Recorder) record(ctx context.Context) error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	cmd := r.buildFFmpegCommand(ctx)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	r.logger.Info("Starting recording", "camera_id", r.camera.ID, "dir", r.dir)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start FFmpeg: %w", err)
+	}
+
+	r.setRecording(true)
+	defer r.setRecording(false)
+
+	go r.monitorOutput(stderr)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("FFmpeg process exited: %w", err)
+	}
+
+	return fmt.Errorf("FFmpeg process exited")
+}
+
+// buildFFmpegCommand builds the FFmpeg segment recording command. Video is
+// copied as-is; audio is only transcoded when the container requires it.
+func (r *Recorder) buildFFmpegCommand(ctx context.Context) *exec.Cmd {
+	rec := r.config.Recording
+
+	args := []string{
+		"-rtsp_transport", "tcp",
+		"-i", r.url,
+		"-map", "0:v",
+		"-map", "0:a?",
+		"-c:v", "copy",
+	}
+
+	if rec.Format == "mp4" {
+		// MP4 cannot carry the G.711 audio most cameras send, and fragmented
+		// MP4 keeps a segment playable if FFmpeg dies before finalizing it
+		args = append(args,
+			"-c:a", "aac",
+			"-f", "segment",
+			"-segment_format", "mp4",
+			"-segment_format_options", "movflags=+frag_keyframe+empty_moov+default_base_moof",
+		)
+	} else {
+		args = append(args,
+			"-c:a", "copy",
+			"-f", "segment",
+			"-segment_format", "matroska",
+		)
+	}
+
+	args = append(args,
+		"-segment_time", strconv.FormatFloat(rec.SegmentDuration.Seconds(), 'f', -1, 64),
+		"-reset_timestamps", "1",
+		"-strftime", "1",
+		filepath.Join(r.dir, segmentPattern+"."+rec.Format),
+	)
+
+	if r.config.FFmpeg.LogLevel != "" {
+		args = append([]string{"-loglevel", r.config.FFmpeg.LogLevel}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	// Interrupt rather than kill so the current segment is finalized
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = stopTimeout
+
+	r.logger.Debug("FFmpeg recording command", "args", strings.Join(args, " "))
+
+	return cmd
+}
+
+// monitorOutput logs FFmpeg output
+func (r *Recorder) monitorOutput(pipe io.ReadCloser) {
+	defer pipe.Close()
+
+	scanner := bufio.NewScanner(pipe)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.Contains(line, "error") || strings.Contains(line, "Error") {
+			r.logger.Error("FFmpeg recording error", "camera_id", r.camera.ID, "message", line)
+		} else {
+			r.logger.Debug("FFmpeg recording output", "camera_id", r.camera.ID, "message", line)
+		}
+	}
+}
+
+// setRecording records whether FFmpeg is running
+func (r *Recorder) setRecording(recording bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recording = recording
+	if recording {
+		r.startTime = time.Now()
+	}
+}
+
+// setLastError records the last error
+func (r *Recorder) setLastError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastError = err
+}
+
+// Info returns a snapshot of the recorder state
+func (r *Recorder) Info() RecorderInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	info := RecorderInfo{
+		CameraID:  r.camera.ID,
+		Recording: r.recording,
+	}
+	if r.recording {
+		info.Since = r.startTime
+	}
+	if r.lastError != nil {
+		info.LastError = r.lastError.Error()
+	}
+	return info
+}
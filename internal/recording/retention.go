@@ -0,0 +1,311 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+	"github.com/cctv-agent/internal/monitor"
+)
+
+// maxPendingEvictions bounds the evictions kept until the next report
+const maxPendingEvictions = 256
+
+// Eviction reasons
+const (
+	EvictionMaxAge  = "max_age"
+	EvictionMaxSize = "max_size"
+	EvictionMinFree = "min_free"
+)
+
+// DiskUsageProvider reports the usage of the filesystem holding a path
+type DiskUsageProvider interface {
+	GetPathDiskUsage(path string) (*monitor.DiskUsage, error)
+}
+
+// Eviction describes a segment deleted by the retention policy
+type Eviction struct {
+	CameraID string
+	File     string
+	Size     int64
+	Reason   string
+	Time     time.Time
+}
+
+// StorageInfo describes the recording directory after the last retention pass
+type StorageInfo struct {
+	Segments  int
+	UsedBytes int64
+	FreeBytes uint64
+	CheckedAt time.Time
+}
+
+// Retention enforces the recording retention policy: a maximum segment age
+// per camera, a maximum total size and a minimum amount of free disk space.
+// Oldest segments are deleted first.
+type Retention struct {
+	config    *config.Config
+	disk      DiskUsageProvider
+	logger    logger.Logger
+	mu        sync.Mutex
+	evictions []Eviction
+	storage   StorageInfo
+	changed   chan struct{} // Signals Run that the configuration changed
+}
+
+// segment is a recorded segment file
+type segment struct {
+	cameraID string
+	path     string
+	size     int64
+	modTime  time.Time
+}
+
+// NewRetention creates a retention enforcer for the recording directory
+func NewRetention(cfg *config.Config, disk DiskUsageProvider, log logger.Logger) *Retention {
+	return &Retention{
+		config:  cfg,
+		disk:    disk,
+		logger:  log,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Run enforces the retention policy every retention interval until the
+// context is cancelled. A new configuration is enforced right away and its
+// interval used from then on.
+func (r *Retention) Run(ctx context.Context) {
+	interval := r.interval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Enforce(time.Now()); err != nil {
+			r.logger.Error("Failed to apply recording retention", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.changed:
+			if next := r.interval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+// interval returns how often the retention policy is applied
+func (r *Retention) interval() time.Duration {
+	if interval := r.getConfig().Recording.RetentionInterval; interval > 0 {
+		return interval
+	}
+	return config.DefaultRetentionInterval
+}
+
+// Enforce applies the retention policy once and returns the evicted
+// segments. The newest segment of each camera is never deleted since FFmpeg
+// may still be writing it.
+func (r *Retention) Enforce(now time.Time) ([]Eviction, error) {
+	cfg := r.getConfig()
+	rec := cfg.Recording
+
+	segments, err := listSegments(rec.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Segments are sorted oldest first, so the last one seen per camera is
+	// the one being written
+	newest := make(map[string]string)
+	var used int64
+	for _, seg := range segments {
+		newest[seg.cameraID] = seg.path
+		used += seg.size
+	}
+
+	var free uint64
+	var needFree uint64
+	if rec.MinFreeMB > 0 && r.disk != nil {
+		usage, err := r.disk.GetPathDiskUsage(rec.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get disk usage: %w", err)
+		}
+		free = usage.Free
+		needFree = uint64(rec.MinFreeMB) << 20
+	}
+	maxBytes := rec.MaxSizeMB << 20
+
+	var evicted []Eviction
+	var errs []error
+	remaining := 0
+	for _, seg := range segments {
+		reason := ""
+		if newest[seg.cameraID] != seg.path {
+			switch {
+			case expired(cfg, seg, now):
+				reason = EvictionMaxAge
+			case maxBytes > 0 && used > maxBytes:
+				reason = EvictionMaxSize
+			case free < needFree:
+				reason = EvictionMinFree
+			}
+		}
+		if reason == "" {
+			remaining++
+			continue
+		}
+
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			remaining++
+			continue
+		}
+		used -= seg.size
+		free += uint64(seg.size)
+
+		evicted = append(evicted, Eviction{
+			CameraID: seg.cameraID,
+			File:     filepath.Base(seg.path),
+			Size:     seg.size,
+			Reason:   reason,
+			Time:     now,
+		})
+		r.logger.Info("Deleted recording segment",
+			"camera_id", seg.cameraID,
+			"file", filepath.Base(seg.path),
+			"size", seg.size,
+			"reason", reason)
+	}
+
+	if free < needFree {
+		r.logger.Warn("Free disk space below recording floor",
+			"dir", rec.Dir,
+			"free_bytes", free,
+			"min_free_bytes", needFree)
+	}
+
+	r.mu.Lock()
+	r.evictions = append(r.evictions, evicted...)
+	if len(r.evictions) > maxPendingEvictions {
+		r.evictions = r.evictions[len(r.evictions)-maxPendingEvictions:]
+	}
+	r.storage = StorageInfo{
+		Segments:  remaining,
+		UsedBytes: used,
+		FreeBytes: free,
+		CheckedAt: now,
+	}
+	r.mu.Unlock()
+
+	return evicted, errors.Join(errs...)
+}
+
+// SetConfig replaces the configuration of the retention policy from its next
+// pass on
+func (r *Retention) SetConfig(cfg *config.Config) {
+	r.mu.Lock()
+	r.config = cfg
+	r.mu.Unlock()
+
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// getConfig returns the current configuration
+func (r *Retention) getConfig() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.config
+}
+
+// expired reports whether a segment is older than its camera's max age
+func expired(cfg *config.Config, seg segment, now time.Time) bool {
+	maxAge := cfg.Recording.MaxAge
+	if camera, err := cfg.GetCameraByID(seg.cameraID); err == nil && camera.RecordingMaxAge > 0 {
+		maxAge = camera.RecordingMaxAge
+	}
+	return maxAge > 0 && now.Sub(seg.modTime) > maxAge
+}
+
+// TakeEvictions returns the evictions since the last call
+func (r *Retention) TakeEvictions() []Eviction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	evictions := r.evictions
+	r.evictions = nil
+	return evictions
+}
+
+// GetStorageInfo returns the state of the recording directory after the
+// last retention pass
+func (r *Retention) GetStorageInfo() StorageInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.storage
+}
+
+// isSegment reports whether a file name looks like a recorded segment
+func isSegment(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".mp4" || ext == ".mkv"
+}
+
+// listSegments returns the segments below root, oldest first
+func listSegments(root string) ([]segment, error) {
+	cameras, err := os.ReadDir(root)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read recording directory: %w", err)
+	}
+
+	var segments []segment
+	for _, camera := range cameras {
+		if !camera.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(root, camera.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !isSegment(entry.Name()) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				// Removed since the directory was read
+				continue
+			}
+			segments = append(segments, segment{
+				cameraID: camera.Name(),
+				path:     filepath.Join(dir, entry.Name()),
+				size:     info.Size(),
+				modTime:  info.ModTime(),
+			})
+		}
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].modTime.Before(segments[j].modTime)
+	})
+
+	return segments, nil
+}
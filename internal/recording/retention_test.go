@@ -0,0 +1,395 @@
+package recording
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+	"github.com/cctv-agent/internal/monitor"
+)
+
+const mb = 1 << 20
+
+// fakeDisk reports a fixed amount of free space
+type fakeDisk struct {
+	free uint64
+	err  error
+}
+
+func (d *fakeDisk) GetPathDiskUsage(path string) (*monitor.DiskUsage, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &monitor.DiskUsage{Path: path, Free: d.free}, nil
+}
+
+// testSegment is a segment file created for a test
+type testSegment struct {
+	camera string
+	name   string
+	size   int64
+	age    time.Duration
+}
+
+// evicted identifies an expected eviction
+type evicted struct {
+	camera string
+	name   string
+	reason string
+}
+
+// writeSegments creates sparse segment files backdated by their age
+func writeSegments(t *testing.T, dir string, now time.Time, segments []testSegment) {
+	t.Helper()
+	for _, seg := range segments {
+		cameraDir := filepath.Join(dir, seg.camera)
+		if err := os.MkdirAll(cameraDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(cameraDir, seg.name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Truncate(seg.size); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		mtime := now.Add(-seg.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// remainingFiles lists the files left below dir as camera/name
+func remainingFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestRetentionEnforce(t *testing.T) {
+	tests := []struct {
+		name      string
+		recording config.RecordingConfig
+		cameras   []config.CameraConfig
+		free      uint64
+		segments  []testSegment
+		want      []evicted
+		remaining []string
+	}{
+		{
+			name:      "global max age",
+			recording: config.RecordingConfig{MaxAge: time.Hour},
+			segments: []testSegment{
+				{"cam1", "a.mp4", mb, 3 * time.Hour},
+				{"cam1", "b.mp4", mb, 2 * time.Hour},
+				{"cam1", "c.mp4", mb, 50 * time.Minute},
+				{"cam1", "d.mp4", mb, time.Minute},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMaxAge},
+				{"cam1", "b.mp4", EvictionMaxAge},
+			},
+			remaining: []string{"cam1/c.mp4", "cam1/d.mp4"},
+		},
+		{
+			name:      "per-camera max age",
+			recording: config.RecordingConfig{MaxAge: 2 * time.Hour},
+			cameras: []config.CameraConfig{
+				{ID: "cam1", RecordingMaxAge: 30 * time.Minute},
+				{ID: "cam2"},
+			},
+			segments: []testSegment{
+				{"cam1", "a.mp4", mb, time.Hour},
+				{"cam2", "a.mp4", mb, 90 * time.Minute},
+				{"cam1", "b.mp4", mb, 45 * time.Minute},
+				{"cam1", "c.mp4", mb, 5 * time.Minute},
+				{"cam2", "b.mp4", mb, 5 * time.Minute},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMaxAge},
+				{"cam1", "b.mp4", EvictionMaxAge},
+			},
+			remaining: []string{"cam1/c.mp4", "cam2/a.mp4", "cam2/b.mp4"},
+		},
+		{
+			name:      "newest segment is kept when expired",
+			recording: config.RecordingConfig{MaxAge: time.Hour},
+			segments: []testSegment{
+				{"cam1", "a.mp4", mb, 5 * time.Hour},
+				{"cam1", "b.mp4", mb, 4 * time.Hour},
+				{"cam2", "a.mkv", mb, 5 * time.Hour},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMaxAge},
+			},
+			remaining: []string{"cam1/b.mp4", "cam2/a.mkv"},
+		},
+		{
+			name:      "free floor deletes oldest first across cameras",
+			recording: config.RecordingConfig{MinFreeMB: 10},
+			free:      7 * mb,
+			segments: []testSegment{
+				{"cam2", "a.mp4", 2 * mb, 50 * time.Minute},
+				{"cam1", "a.mp4", 2 * mb, 40 * time.Minute},
+				{"cam2", "b.mp4", 2 * mb, 30 * time.Minute},
+				{"cam1", "b.mp4", 2 * mb, 20 * time.Minute},
+				{"cam1", "c.mp4", 2 * mb, time.Minute},
+				{"cam2", "c.mp4", 2 * mb, time.Minute},
+			},
+			want: []evicted{
+				{"cam2", "a.mp4", EvictionMinFree},
+				{"cam1", "a.mp4", EvictionMinFree},
+			},
+			remaining: []string{"cam1/b.mp4", "cam1/c.mp4", "cam2/b.mp4", "cam2/c.mp4"},
+		},
+		{
+			name:      "free floor never deletes the newest segments",
+			recording: config.RecordingConfig{MinFreeMB: 100},
+			free:      mb,
+			segments: []testSegment{
+				{"cam1", "a.mp4", 2 * mb, 30 * time.Minute},
+				{"cam2", "a.mp4", 2 * mb, 20 * time.Minute},
+				{"cam1", "b.mp4", 2 * mb, 10 * time.Minute},
+				{"cam2", "b.mp4", 2 * mb, time.Minute},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMinFree},
+				{"cam2", "a.mp4", EvictionMinFree},
+			},
+			remaining: []string{"cam1/b.mp4", "cam2/b.mp4"},
+		},
+		{
+			name:      "max size",
+			recording: config.RecordingConfig{MaxSizeMB: 5},
+			segments: []testSegment{
+				{"cam1", "a.mp4", 2 * mb, 40 * time.Minute},
+				{"cam1", "b.mp4", 2 * mb, 30 * time.Minute},
+				{"cam1", "c.mp4", 2 * mb, 20 * time.Minute},
+				{"cam1", "d.mp4", 2 * mb, 10 * time.Minute},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMaxSize},
+				{"cam1", "b.mp4", EvictionMaxSize},
+			},
+			remaining: []string{"cam1/c.mp4", "cam1/d.mp4"},
+		},
+		{
+			name:      "age is reported before size",
+			recording: config.RecordingConfig{MaxAge: time.Hour, MaxSizeMB: 3},
+			segments: []testSegment{
+				{"cam1", "a.mp4", 2 * mb, 2 * time.Hour},
+				{"cam1", "b.mp4", 2 * mb, 30 * time.Minute},
+				{"cam1", "c.mp4", 2 * mb, 20 * time.Minute},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMaxAge},
+				{"cam1", "b.mp4", EvictionMaxSize},
+			},
+			remaining: []string{"cam1/c.mp4"},
+		},
+		{
+			name:      "within limits",
+			recording: config.RecordingConfig{MaxAge: time.Hour, MaxSizeMB: 100, MinFreeMB: 10},
+			free:      50 * mb,
+			segments: []testSegment{
+				{"cam1", "a.mp4", mb, 30 * time.Minute},
+				{"cam1", "b.mp4", mb, time.Minute},
+			},
+			remaining: []string{"cam1/a.mp4", "cam1/b.mp4"},
+		},
+		{
+			name:      "other files are ignored",
+			recording: config.RecordingConfig{MaxAge: time.Hour},
+			segments: []testSegment{
+				{"cam1", "notes.txt", mb, 5 * time.Hour},
+				{"cam1", "a.mp4", mb, 4 * time.Hour},
+				{"cam1", "b.mp4", mb, time.Minute},
+			},
+			want: []evicted{
+				{"cam1", "a.mp4", EvictionMaxAge},
+			},
+			remaining: []string{"cam1/b.mp4", "cam1/notes.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			writeSegments(t, dir, now, tt.segments)
+
+			cfg := &config.Config{Recording: tt.recording, Cameras: tt.cameras}
+			cfg.Recording.Dir = dir
+			r := NewRetention(cfg, &fakeDisk{free: tt.free}, logger.NewNopLogger())
+
+			evictions, err := r.Enforce(now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []evicted
+			var evictedBytes int64
+			for _, e := range evictions {
+				got = append(got, evicted{e.CameraID, e.File, e.Reason})
+				evictedBytes += e.Size
+				if !e.Time.Equal(now) {
+					t.Errorf("eviction of %s at %v, want %v", e.File, e.Time, now)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evicted %v, want %v", got, tt.want)
+			}
+
+			if files := remainingFiles(t, dir); !reflect.DeepEqual(files, tt.remaining) {
+				t.Errorf("remaining files %v, want %v", files, tt.remaining)
+			}
+
+			// Evictions are reported once, with the storage left behind
+			if taken := r.TakeEvictions(); !reflect.DeepEqual(taken, evictions) {
+				t.Errorf("TakeEvictions = %v, want %v", taken, evictions)
+			}
+			if taken := r.TakeEvictions(); len(taken) != 0 {
+				t.Errorf("evictions reported twice: %v", taken)
+			}
+
+			var total int64
+			segments := 0
+			for _, seg := range tt.segments {
+				if isSegment(seg.name) {
+					total += seg.size
+					segments++
+				}
+			}
+			storage := r.GetStorageInfo()
+			if storage.Segments != segments-len(evictions) || storage.UsedBytes != total-evictedBytes {
+				t.Errorf("storage = %d segments of %d bytes, want %d of %d",
+					storage.Segments, storage.UsedBytes, segments-len(evictions), total-evictedBytes)
+			}
+			if tt.recording.MinFreeMB > 0 && storage.FreeBytes != tt.free+uint64(evictedBytes) {
+				t.Errorf("free bytes = %d, want %d", storage.FreeBytes, tt.free+uint64(evictedBytes))
+			}
+		})
+	}
+}
+
+func TestRetentionEnforceMissingDir(t *testing.T) {
+	cfg := &config.Config{Recording: config.RecordingConfig{
+		Dir:    filepath.Join(t.TempDir(), "missing"),
+		MaxAge: time.Hour,
+	}}
+	r := NewRetention(cfg, &fakeDisk{}, logger.NewNopLogger())
+
+	evictions, err := r.Enforce(time.Now())
+	if err != nil || len(evictions) != 0 {
+		t.Errorf("Enforce = %v, %v; want nothing to do", evictions, err)
+	}
+}
+
+func TestRetentionEnforceDiskError(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeSegments(t, dir, now, []testSegment{
+		{"cam1", "a.mp4", mb, 2 * time.Hour},
+		{"cam1", "b.mp4", mb, time.Minute},
+	})
+
+	cfg := &config.Config{Recording: config.RecordingConfig{Dir: dir, MaxAge: time.Hour, MinFreeMB: 10}}
+	diskErr := errors.New("statfs failed")
+	r := NewRetention(cfg, &fakeDisk{err: diskErr}, logger.NewNopLogger())
+
+	if _, err := r.Enforce(now); !errors.Is(err, diskErr) {
+		t.Fatalf("Enforce error = %v, want %v", err, diskErr)
+	}
+	// Nothing is deleted without knowing the free space
+	if files := remainingFiles(t, dir); len(files) != 2 {
+		t.Errorf("remaining files %v, want both segments", files)
+	}
+}
+
+func TestRetentionSetConfig(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeSegments(t, dir, now, []testSegment{
+		{"cam1", "a.mp4", mb, 2 * time.Hour},
+		{"cam1", "b.mp4", mb, time.Minute},
+	})
+
+	r := NewRetention(&config.Config{Recording: config.RecordingConfig{Dir: dir}}, nil, logger.NewNopLogger())
+	if evictions, _ := r.Enforce(now); len(evictions) != 0 {
+		t.Fatalf("evicted %v without a max age", evictions)
+	}
+
+	r.SetConfig(&config.Config{Recording: config.RecordingConfig{Dir: dir, MaxAge: time.Hour}})
+	evictions, err := r.Enforce(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evictions) != 1 || evictions[0].File != "a.mp4" {
+		t.Errorf("evicted %v, want a.mp4 under the new max age", evictions)
+	}
+}
+
+func TestRetentionRunIntervalChange(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRetention(&config.Config{Recording: config.RecordingConfig{Dir: dir, RetentionInterval: time.Hour}},
+		nil, logger.NewNopLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// waitPasses waits for n retention passes after the last one seen
+	last := time.Time{}
+	waitPasses := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for n > 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("%d retention passes missing", n)
+			}
+			if checked := r.GetStorageInfo().CheckedAt; checked.After(last) {
+				last = checked
+				n--
+				continue
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	waitPasses(1)
+
+	// The hourly interval is replaced without waiting for its tick
+	r.SetConfig(&config.Config{Recording: config.RecordingConfig{Dir: dir, RetentionInterval: 10 * time.Millisecond}})
+	waitPasses(3)
+}
@@ -3,8 +3,10 @@ package socketio
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/zishang520/engine.io/v2/events"
 	"net/url"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -91,8 +93,13 @@ func (c *Client) Connect() error {
 	// Register core socket events
 	// connect
 	io.On(events.EventName("connect"), func(args ...any) {
-		c.logger.Info("Connected to Socket.IO server")
 		c.mu.Lock()
+		if c.socket != io {
+			// A socket replaced by Reconnect
+			c.mu.Unlock()
+			return
+		}
+		c.logger.Info("Connected to Socket.IO server")
 		c.connected = true
 		c.reconnecting = false
 		c.mu.Unlock()
@@ -102,8 +109,12 @@ func (c *Client) Connect() error {
 	})
 	// disconnect
 	io.On(events.EventName("disconnect"), func(args ...any) {
-		c.logger.Warn("Disconnected from Socket.IO server")
 		c.mu.Lock()
+		if c.socket != io {
+			c.mu.Unlock()
+			return
+		}
+		c.logger.Warn("Disconnected from Socket.IO server")
 		c.connected = false
 		c.mu.Unlock()
 		if c.onDisconnect != nil {
@@ -121,7 +132,7 @@ func (c *Client) Connect() error {
 		ev := event
 		h := handler
 		io.On(events.EventName(ev), func(args ...any) {
-			b, err := json.Marshal(args)
+			b, err := json.Marshal(eventPayload(args))
 			if err != nil {
 				c.logger.Error("Failed to marshal event args", "event", ev, "error", err)
 				return
@@ -157,6 +168,27 @@ func (c *Client) Disconnect() error {
 	return nil
 }
 
+// Reconnect closes the connection and connects to the server at raw, which
+// may differ from the URL the client was created with
+func (c *Client) Reconnect(raw string) error {
+	c.mu.Lock()
+	// Stop a pending reconnection to the old server
+	c.cancel()
+	if c.manager != nil {
+		c.manager.Clear()
+	}
+	c.manager = nil
+	c.socket = nil
+	c.connected = false
+	c.reconnecting = false
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.rawURL = raw
+	c.parseURL()
+	c.mu.Unlock()
+
+	return c.Connect()
+}
+
 // Emit sends an event to the server
 func (c *Client) Emit(event string, data interface{}) error {
 	c.mu.RLock()
@@ -210,6 +242,7 @@ func (c *Client) reconnect() {
 		return
 	}
 	c.reconnecting = true
+	ctx := c.ctx
 	c.mu.Unlock()
 
 	ticker := time.NewTicker(5 * time.Second)
@@ -217,7 +250,7 @@ func (c *Client) reconnect() {
 
 	for {
 		select {
-		case <-c.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			c.logger.Info("Attempting to reconnect to Socket.IO server")
@@ -230,6 +263,33 @@ func (c *Client) reconnect() {
 	}
 }
 
+// eventPayload strips acknowledgement callbacks from event arguments so the
+// remaining values can be marshalled for the handlers
+func eventPayload(args []any) []any {
+	payload := make([]any, 0, len(args))
+	for _, arg := range args {
+		if arg != nil && reflect.TypeOf(arg).Kind() == reflect.Func {
+			continue
+		}
+		payload = append(payload, arg)
+	}
+	return payload
+}
+
+// DecodeEventData decodes an event payload into v.
+// Handlers receive the raw Socket.IO argument list, so the first argument is
+// unwrapped when the payload is a JSON array.
+func DecodeEventData(data json.RawMessage, v interface{}) error {
+	var args []json.RawMessage
+	if err := json.Unmarshal(data, &args); err == nil {
+		if len(args) == 0 {
+			return errors.New("empty event payload")
+		}
+		data = args[0]
+	}
+	return json.Unmarshal(data, v)
+}
+
 // SendMessage sends a message with a specific event type
 func (c *Client) SendMessage(msg Message) error {
 	return c.Emit(msg.Type, msg.Data)
@@ -27,16 +27,89 @@ type StatusReport struct {
 	Uptime       time.Duration             `json:"uptime"`
 	CameraStatus map[string]CameraStatus   `json:"camera_status"`
 	SystemInfo   SystemInfo                `json:"system_info"`
+	Recording    *RecordingStatus          `json:"recording,omitempty"`
 	Timestamp    time.Time                 `json:"timestamp"`
 }
 
 // CameraStatus represents individual camera status
 type CameraStatus struct {
-	ID         string    `json:"id"`
-	Connected  bool      `json:"connected"`
-	Streaming  bool      `json:"streaming"`
-	LastUpdate time.Time `json:"last_update"`
-	Error      string    `json:"error,omitempty"`
+	ID             string         `json:"id"`
+	Status         string         `json:"status"`
+	Connected      bool           `json:"connected"`
+	Streaming      bool           `json:"streaming"`
+	Recording      bool           `json:"recording"`
+	Uptime         time.Duration  `json:"uptime"`
+	RetryCount     int            `json:"retry_count"`
+	NextRetry      *time.Time     `json:"next_retry,omitempty"`
+	CircuitOpen    bool           `json:"circuit_open,omitempty"`
+	LastTransition time.Time      `json:"last_transition"`
+	LastUpdate     time.Time      `json:"last_update"`
+	Error          string         `json:"error,omitempty"`
+	Profile        *MediaProfile  `json:"profile,omitempty"`
+	Stats          *StreamStats   `json:"stats,omitempty"`
+	Encoding       string         `json:"encoding,omitempty"` // transcode, copy or copy_video
+	Source         *SourceInfo    `json:"source,omitempty"`
+	Outputs        []OutputStatus `json:"outputs,omitempty"`
+}
+
+// OutputStatus represents the state of one of a camera's stream outputs
+type OutputStatus struct {
+	Name   string    `json:"name"`
+	Type   string    `json:"type"`
+	Status string    `json:"status"` // active, failed or stopped
+	Error  string    `json:"error,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// SourceInfo describes a camera's source streams as probed by ffprobe
+type SourceInfo struct {
+	VideoCodec string    `json:"video_codec"`
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	FPS        float64   `json:"fps"`
+	AudioCodec string    `json:"audio_codec,omitempty"`
+	HasAudio   bool      `json:"has_audio"`
+	ProbedAt   time.Time `json:"probed_at"`
+}
+
+// StreamStats represents the encoding statistics reported by FFmpeg
+type StreamStats struct {
+	FPS              float64       `json:"fps"`
+	Bitrate          float64       `json:"bitrate"` // kbit/s
+	Frames           uint64        `json:"frames"`
+	DroppedFrames    uint64        `json:"dropped_frames"`
+	DuplicatedFrames uint64        `json:"duplicated_frames"`
+	Speed            float64       `json:"speed"`
+	OutTime          time.Duration `json:"out_time"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+}
+
+// MediaProfile describes the ONVIF media profile a camera streams from
+type MediaProfile struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Encoding string `json:"encoding"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FPS      int    `json:"fps"`
+	Bitrate  int    `json:"bitrate"` // kbit/s
+}
+
+// RecordingStatus reports the local recording storage
+type RecordingStatus struct {
+	Segments  int               `json:"segments"`
+	UsedBytes int64             `json:"used_bytes"`
+	FreeBytes uint64            `json:"free_bytes"`
+	Evictions []SegmentEviction `json:"evictions,omitempty"`
+}
+
+// SegmentEviction reports a segment deleted by the retention policy
+type SegmentEviction struct {
+	CameraID string    `json:"camera_id"`
+	File     string    `json:"file"`
+	Size     int64     `json:"size"`
+	Reason   string    `json:"reason"` // max_age, max_size, min_free
+	Time     time.Time `json:"time"`
 }
 
 // SystemInfo represents system information
@@ -78,18 +151,40 @@ type NetworkInfo struct {
 
 // Command represents a command from the server
 type Command struct {
+	ID       string          `json:"id"`
 	Type     string          `json:"type"`
 	CameraID string          `json:"camera_id,omitempty"`
 	Data     json.RawMessage `json:"data"`
 }
 
+// Command result statuses
+const (
+	CommandStatusSuccess = "success"
+	CommandStatusError   = "error"
+)
+
+// CommandResult reports the outcome of a command back to the server
+type CommandResult struct {
+	ID        string        `json:"id"`
+	Type      string        `json:"type"`
+	CameraID  string        `json:"camera_id,omitempty"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+	Data      interface{}   `json:"data,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
 // PTZCommand represents PTZ control command
 type PTZCommand struct {
-	Action string  `json:"action"`
-	Pan    float64 `json:"pan,omitempty"`
-	Tilt   float64 `json:"tilt,omitempty"`
-	Zoom   float64 `json:"zoom,omitempty"`
-	Preset int     `json:"preset,omitempty"`
+	Action      string  `json:"action"` // move, stop, goto_preset, set_preset, remove_preset
+	Pan         float64 `json:"pan,omitempty"`
+	Tilt        float64 `json:"tilt,omitempty"`
+	Zoom        float64 `json:"zoom,omitempty"`
+	Speed       float64 `json:"speed,omitempty"`
+	Preset      int     `json:"preset,omitempty"`
+	PresetToken string  `json:"preset_token,omitempty"`
+	PresetName  string  `json:"preset_name,omitempty"`
 }
 
 // StreamCommand represents stream control command
@@ -99,6 +194,36 @@ type StreamCommand struct {
 
 // UpdateCommand represents update command
 type UpdateCommand struct {
-	Version string `json:"version"`
-	URL     string `json:"url"`
+	Version  string `json:"version"`
+	URL      string `json:"url"`
+	Checksum string `json:"checksum,omitempty"`
+	Force    bool   `json:"force,omitempty"`
+}
+
+// DiscoverCommand represents an ONVIF discovery command
+type DiscoverCommand struct {
+	Timeout int `json:"timeout,omitempty"` // seconds
+}
+
+// SnapshotResult is the data of a snapshot command result. Image is encoded
+// as base64 in JSON.
+type SnapshotResult struct {
+	ContentType string    `json:"content_type"`
+	Source      string    `json:"source"` // onvif or ffmpeg
+	Size        int       `json:"size"`
+	TakenAt     time.Time `json:"taken_at"`
+	Image       []byte    `json:"image"`
+}
+
+// ConfigUpdateCommand represents a configuration update pushed by the server.
+// Config is a full configuration or a JSON merge patch of the current one.
+type ConfigUpdateCommand struct {
+	Config   json.RawMessage `json:"config,omitempty"`
+	Rollback bool            `json:"rollback,omitempty"` // Restore the configuration replaced by the last update
+}
+
+// ConfigUpdateResult is the data of a config_update command result
+type ConfigUpdateResult struct {
+	Changes         []string `json:"changes"`
+	RestartRequired []string `json:"restart_required,omitempty"` // Sections applied after a restart
 }
@@ -2,6 +2,7 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -20,8 +21,12 @@ const (
 	StatusConnected    StreamStatus = "connected"
 	StatusError        StreamStatus = "error"
 	StatusReconnecting StreamStatus = "reconnecting"
+	StatusStalled      StreamStatus = "stalled"
 )
 
+// stallCheckInterval is how often streams are checked for stalls
+const stallCheckInterval = time.Second
+
 // StatusUpdate represents a stream status update
 type StatusUpdate struct {
 	CameraID  string
@@ -33,15 +38,27 @@ type StatusUpdate struct {
 // Manager manages multiple camera streams
 type Manager struct {
 	config       *config.Config
+	configMu     sync.RWMutex
 	logger       logger.Logger
 	streams      map[string]*Stream
 	statusChan   chan StatusUpdate
+	statusClosed bool
+	statusMu     sync.RWMutex
 	mu           sync.RWMutex
 	ctx          context.Context
 	cancel       context.CancelFunc
 	eg           *errgroup.Group
-	maxRetries   int
-	retryDelay   time.Duration
+	sourceURLs   map[string]SourceURLs
+	sourceMu     sync.RWMutex
+	snapshotSem  chan struct{}
+}
+
+// SourceURLs holds stream URLs resolved at runtime for a camera, e.g. from
+// its ONVIF media profiles
+type SourceURLs struct {
+	Main     string
+	Sub      string
+	Snapshot string // HTTP URI of JPEG snapshots
 }
 
 // NewManager creates a new stream manager
@@ -50,15 +67,15 @@ func NewManager(cfg *config.Config, log logger.Logger) *Manager {
 	eg, egCtx := errgroup.WithContext(ctx)
 	
 	return &Manager{
-		config:     cfg,
-		logger:     log,
-		streams:    make(map[string]*Stream),
-		statusChan: make(chan StatusUpdate, 100),
-		ctx:        egCtx,
-		cancel:     cancel,
-		eg:         eg,
-		maxRetries: 3,
-		retryDelay: 5 * time.Second,
+		config:      cfg,
+		logger:      log,
+		streams:     make(map[string]*Stream),
+		statusChan:  make(chan StatusUpdate, 100),
+		ctx:         egCtx,
+		cancel:      cancel,
+		eg:          eg,
+		sourceURLs:  make(map[string]SourceURLs),
+		snapshotSem: make(chan struct{}, maxConcurrentSnapshots),
 	}
 }
 
@@ -66,107 +83,229 @@ func NewManager(cfg *config.Config, log logger.Logger) *Manager {
 func (m *Manager) Start() error {
 	m.logger.Info("Starting stream manager")
 	
-	cameras := m.config.GetEnabledCameras()
+	cfg := m.getConfig()
+
+	// Restart streams that stop making progress, including cameras added later
+	m.eg.Go(m.watchStalls)
+
+	cameras := cfg.GetEnabledCameras()
 	if len(cameras) == 0 {
 		m.logger.Warn("No enabled cameras found")
 		return nil
 	}
 	
 	// Create semaphore for concurrency control
-	sem := make(chan struct{}, m.config.Agent.MaxConcurrency)
+	sem := make(chan struct{}, cfg.Agent.MaxConcurrency)
 	
 	for _, camera := range cameras {
 		cam := camera // Capture loop variable
 		
+		if m.SourceURL(&cam) == "" {
+			m.logger.Error("Camera has no RTSP URL, skipping", "camera_id", cam.ID)
+			continue
+		}
+		
 		// Create stream instance
-		stream := NewStream(&cam, m.config, m.logger.With("camera_id", cam.ID))
+		stream := m.newStream(&cam)
 		
 		m.mu.Lock()
 		m.streams[cam.ID] = stream
 		m.mu.Unlock()
 		
 		// Start stream in goroutine with concurrency control
-		m.eg.Go(func() error {
-			sem <- struct{}{}        // Acquire semaphore
-			defer func() { <-sem }() // Release semaphore
-			
-			return m.runStreamWithRetry(stream)
-		})
+		m.supervise(stream, sem)
 	}
 	
 	m.logger.Info("Stream manager started", "camera_count", len(cameras))
 	return nil
 }
 
-// runStreamWithRetry runs a stream with automatic retry on failure
-func (m *Manager) runStreamWithRetry(stream *Stream) error {
-	retryCount := 0
-	
+// SetConfig replaces the configuration used for streams created from now on.
+// Running streams keep the configuration they were started with.
+func (m *Manager) SetConfig(cfg *config.Config) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	m.config = cfg
+}
+
+// getConfig returns the current configuration
+func (m *Manager) getConfig() *config.Config {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return m.config
+}
+
+// SetSourceURLs sets the stream URLs resolved for a camera. They are used
+// when the camera configuration has no RTSP URL of its own.
+func (m *Manager) SetSourceURLs(cameraID string, urls SourceURLs) {
+	m.sourceMu.Lock()
+	defer m.sourceMu.Unlock()
+	m.sourceURLs[cameraID] = urls
+}
+
+// GetSourceURLs returns the stream URLs resolved for a camera
+func (m *Manager) GetSourceURLs(cameraID string) (SourceURLs, bool) {
+	m.sourceMu.RLock()
+	defer m.sourceMu.RUnlock()
+	urls, exists := m.sourceURLs[cameraID]
+	return urls, exists
+}
+
+// SourceURL returns the RTSP URL to stream a camera from
+func (m *Manager) SourceURL(camera *config.CameraConfig) string {
+	if camera.RTSPUrl != "" {
+		return camera.RTSPUrl
+	}
+	urls, _ := m.GetSourceURLs(camera.ID)
+	return urls.Main
+}
+
+// newStream creates a stream whose status transitions are published on the
+// status channel
+func (m *Manager) newStream(camera *config.CameraConfig) *Stream {
+	if camera.RTSPUrl == "" {
+		cam := *camera
+		cam.RTSPUrl = m.SourceURL(camera)
+		camera = &cam
+	}
+	stream := NewStream(camera, m.getConfig(), m.logger.With("camera_id", camera.ID))
+	stream.onStatusChange = m.sendStatusUpdate
+	return stream
+}
+
+// supervise runs a stream in the background until it is stopped or the
+// manager shuts down. When sem is set, a slot is held while the stream runs.
+func (m *Manager) supervise(stream *Stream, sem chan struct{}) {
+	stop, done := stream.beginSupervision()
+
+	m.eg.Go(func() error {
+		defer close(done)
+
+		if sem != nil {
+			select {
+			case sem <- struct{}{}: // Acquire semaphore
+			case <-stop:
+				return nil
+			case <-m.ctx.Done():
+				return m.ctx.Err()
+			}
+			defer func() { <-sem }() // Release semaphore
+		}
+
+		return m.runStreamWithRetry(stream, stop)
+	})
+}
+
+// runStreamWithRetry runs a stream, restarting it according to the camera's
+// retry policy until it is stopped or the manager shuts down
+func (m *Manager) runStreamWithRetry(stream *Stream, stop <-chan struct{}) error {
+	policy := NewRetryPolicy(stream.camera)
+	failures := 0
+
 	for {
 		select {
 		case <-m.ctx.Done():
 			return m.ctx.Err()
+		case <-stop:
+			return nil
 		default:
 		}
-		
-		// Update status
-		m.sendStatusUpdate(stream.camera.ID, StatusConnecting, "")
-		
-		// Start stream
+
+		started := time.Now()
 		err := stream.Start(m.ctx)
-		
+
+		select {
+		case <-m.ctx.Done():
+			return m.ctx.Err()
+		case <-stop:
+			return nil
+		default:
+		}
+
+		failures = countFailures(failures, time.Since(started), err)
+
+		var delay time.Duration
+		circuitOpen := false
 		if err != nil {
-			retryCount++
-			
-			if retryCount > m.maxRetries && m.maxRetries > 0 {
-				m.logger.Error("Max retries exceeded for stream", 
-					"camera_id", stream.camera.ID,
-					"retries", retryCount,
-					"error", err)
-				m.sendStatusUpdate(stream.camera.ID, StatusError, err.Error())
-				return err
-			}
-			
+			delay, circuitOpen = policy.Next(failures)
+		} else {
+			// Stream ended normally (shouldn't happen for continuous streams)
+			m.logger.Info("Stream ended", "camera_id", stream.camera.ID)
+			delay = policy.BaseDelay
+		}
+
+		stream.setRetryState(RetryState{
+			Failures:    failures,
+			NextAttempt: time.Now().Add(delay),
+			CircuitOpen: circuitOpen,
+		})
+
+		switch {
+		case err == nil:
+		case circuitOpen:
+			m.logger.Error("Stream keeps failing, pausing retries",
+				"camera_id", stream.camera.ID,
+				"failures", failures,
+				"cooldown", delay,
+				"error", err)
+			stream.setStatus(StatusError)
+		default:
 			m.logger.Warn("Stream failed, retrying",
 				"camera_id", stream.camera.ID,
-				"retry", retryCount,
+				"failures", failures,
+				"delay", delay,
 				"error", err)
-			
-			m.sendStatusUpdate(stream.camera.ID, StatusReconnecting, err.Error())
-			
-			// Wait before retry with exponential backoff
-			delay := m.retryDelay * time.Duration(retryCount)
-			if delay > 30*time.Second {
-				delay = 30 * time.Second
-			}
-			
-			select {
-			case <-time.After(delay):
-				continue
-			case <-m.ctx.Done():
-				return m.ctx.Err()
+			// Stalled streams report their status until restarted
+			if !errors.Is(err, ErrStalled) {
+				stream.setStatus(StatusReconnecting)
 			}
 		}
-		
-		// Stream ended normally (shouldn't happen for continuous streams)
-		m.logger.Info("Stream ended", "camera_id", stream.camera.ID)
-		m.sendStatusUpdate(stream.camera.ID, StatusDisconnected, "")
-		
-		// Reset retry count on successful connection
-		if retryCount > 0 {
-			retryCount = 0
-		}
-		
-		// Wait before reconnecting
+
 		select {
-		case <-time.After(m.retryDelay):
-			continue
+		case <-time.After(delay):
+		case <-stop:
+			return nil
 		case <-m.ctx.Done():
 			return m.ctx.Err()
 		}
 	}
 }
 
+// watchStalls kills streams whose FFmpeg process has not made progress
+// within their stall timeout, so that they are restarted. Streams are checked
+// at a fixed interval since cameras added or updated later may use shorter
+// timeouts than any camera present at startup.
+func (m *Manager) watchStalls() error {
+	ticker := time.NewTicker(stallCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			m.mu.RLock()
+			streams := make([]*Stream, 0, len(m.streams))
+			for _, stream := range m.streams {
+				streams = append(streams, stream)
+			}
+			m.mu.RUnlock()
+
+			for _, stream := range streams {
+				timeout := stream.stallTimeout()
+				idle := stream.stalledFor(now)
+				if timeout <= 0 || idle <= timeout {
+					continue
+				}
+				m.logger.Warn("Stream stalled, restarting",
+					"camera_id", stream.camera.ID,
+					"idle", idle)
+				stream.killStalled(idle)
+			}
+		}
+	}
+}
+
 // Stop stops all streams
 func (m *Manager) Stop() {
 	m.logger.Info("Stopping stream manager")
@@ -180,7 +319,10 @@ func (m *Manager) Stop() {
 	}
 	
 	// Close status channel
+	m.statusMu.Lock()
+	m.statusClosed = true
 	close(m.statusChan)
+	m.statusMu.Unlock()
 	
 	m.logger.Info("Stream manager stopped")
 }
@@ -197,18 +339,22 @@ func (m *Manager) RestartStream(cameraID string) error {
 	
 	m.logger.Info("Restarting stream", "camera_id", cameraID)
 	
-	// Stop the stream
+	// Stop the stream and wait for its supervisor to exit
 	stream.Stop()
+	stream.waitSupervision()
 	
-	// Wait a moment
-	time.Sleep(2 * time.Second)
-	
-	// Start it again
-	go m.runStreamWithRetry(stream)
+	// Start it again with a fresh retry policy
+	stream.setRetryState(RetryState{})
+	m.supervise(stream, nil)
 	
 	return nil
 }
 
+// IsRunning reports whether the manager is still supervising streams
+func (m *Manager) IsRunning() bool {
+	return m.ctx.Err() == nil
+}
+
 // GetStatus returns the status of all streams
 func (m *Manager) GetStatus() map[string]StreamStatus {
 	m.mu.RLock()
@@ -222,6 +368,19 @@ func (m *Manager) GetStatus() map[string]StreamStatus {
 	return status
 }
 
+// GetStreamInfo returns a snapshot of the state of all streams
+func (m *Manager) GetStreamInfo() map[string]StreamInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	info := make(map[string]StreamInfo, len(m.streams))
+	for id, stream := range m.streams {
+		info[id] = stream.Info()
+	}
+
+	return info
+}
+
 // GetStreamStatus returns the status of a specific stream
 func (m *Manager) GetStreamStatus(cameraID string) (StreamStatus, error) {
 	m.mu.RLock()
@@ -249,6 +408,12 @@ func (m *Manager) sendStatusUpdate(cameraID string, status StreamStatus, errorMs
 		Timestamp: time.Now(),
 	}
 	
+	m.statusMu.RLock()
+	defer m.statusMu.RUnlock()
+	if m.statusClosed {
+		return
+	}
+
 	select {
 	case m.statusChan <- update:
 	default:
@@ -266,24 +431,26 @@ func (m *Manager) AddCamera(camera *config.CameraConfig) error {
 		return fmt.Errorf("camera already exists: %s", camera.ID)
 	}
 	
-	stream := NewStream(camera, m.config, m.logger.With("camera_id", camera.ID))
+	if m.SourceURL(camera) == "" {
+		return fmt.Errorf("camera has no RTSP URL: %s", camera.ID)
+	}
+	
+	stream := m.newStream(camera)
 	m.streams[camera.ID] = stream
 	
 	// Start stream in background
-	m.eg.Go(func() error {
-		return m.runStreamWithRetry(stream)
-	})
+	m.supervise(stream, nil)
 	
 	return nil
 }
 
-// RemoveCamera removes a camera stream
+// RemoveCamera removes a camera stream and waits for its FFmpeg process to
+// exit
 func (m *Manager) RemoveCamera(cameraID string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	
 	stream, exists := m.streams[cameraID]
 	if !exists {
+		m.mu.Unlock()
 		return fmt.Errorf("camera not found: %s", cameraID)
 	}
 	
@@ -292,6 +459,10 @@ func (m *Manager) RemoveCamera(cameraID string) error {
 	
 	// Remove from map
 	delete(m.streams, cameraID)
+	m.mu.Unlock()
+
+	// Wait outside the lock so that other streams can report their status
+	stream.waitSupervision()
 	
 	return nil
 }
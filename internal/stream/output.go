@@ -0,0 +1,135 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cctv-agent/config"
+)
+
+// OutputState represents the state of a single stream output
+type OutputState string
+
+const (
+	OutputActive  OutputState = "active"
+	OutputFailed  OutputState = "failed"
+	OutputStopped OutputState = "stopped"
+)
+
+// OutputStatus reports the state of a single stream output
+type OutputStatus struct {
+	Name  string
+	Type  string
+	State OutputState
+	Error string
+	Since time.Time
+}
+
+// teeFailure matches the line the tee muxer logs when it drops an output
+var teeFailure = regexp.MustCompile(`Slave muxer #(\d+) failed: (.*?)(?:, continuing with|$)`)
+
+// parseTeeFailure returns the index and error of an output dropped by the
+// tee muxer
+func parseTeeFailure(line string) (int, string, bool) {
+	match := teeFailure.FindStringSubmatch(line)
+	if match == nil {
+		return 0, "", false
+	}
+	index, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, "", false
+	}
+	return index, strings.TrimSpace(match[2]), true
+}
+
+// outputArgs returns the FFmpeg arguments writing to the outputs. A single
+// output is written directly, several are written by the tee muxer which
+// keeps the others running when one fails.
+func outputArgs(outputs []config.OutputConfig) []string {
+	if len(outputs) == 1 {
+		format, target, options := outputTarget(outputs[0])
+		args := []string{}
+		if format != "" {
+			args = append(args, "-f", format)
+		}
+		for _, option := range options {
+			args = append(args, "-"+option[0], option[1])
+		}
+		return append(args, target)
+	}
+
+	slaves := make([]string, 0, len(outputs))
+	for _, output := range outputs {
+		format, target, options := outputTarget(output)
+		spec := []string{}
+		if format != "" {
+			spec = append(spec, "f="+format)
+		}
+		for _, option := range options {
+			spec = append(spec, option[0]+"="+option[1])
+		}
+		spec = append(spec, "onfail=ignore")
+		slaves = append(slaves, "["+strings.Join(spec, ":")+"]"+teeEscape(target))
+	}
+
+	return []string{
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-f", "tee",
+		strings.Join(slaves, "|"),
+	}
+}
+
+// outputTarget returns the muxer, target and muxer options of an output
+func outputTarget(output config.OutputConfig) (string, string, [][2]string) {
+	switch output.Type {
+	case config.OutputTypeSRT:
+		return "mpegts", output.URL, nil
+	case config.OutputTypeFile:
+		return "", output.Path, nil
+	case config.OutputTypeHLS:
+		return "hls", filepath.Join(output.Path, "index.m3u8"), [][2]string{
+			{"hls_time", strconv.FormatFloat(output.HLSTime.Seconds(), 'f', -1, 64)},
+			{"hls_list_size", strconv.Itoa(output.HLSListSize)},
+			{"hls_flags", "delete_segments"},
+		}
+	default:
+		return "flv", output.URL, nil
+	}
+}
+
+// teeEscape escapes the characters the tee muxer splits outputs on
+func teeEscape(target string) string {
+	var b strings.Builder
+	for _, r := range target {
+		if strings.ContainsRune(`\'|`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// prepareOutputs creates the directories local outputs write to
+func prepareOutputs(outputs []config.OutputConfig) error {
+	for _, output := range outputs {
+		dir := ""
+		switch output.Type {
+		case config.OutputTypeFile:
+			dir = filepath.Dir(output.Path)
+		case config.OutputTypeHLS:
+			dir = output.Path
+		default:
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory for output %s: %w", output.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cctv-agent/config"
+)
+
+// probeTimeout bounds how long ffprobe may take to inspect a source
+const probeTimeout = 15 * time.Second
+
+// Encoding describes how FFmpeg encodes a stream for RTMP
+type Encoding string
+
+const (
+	EncodingTranscode Encoding = "transcode"  // Video and audio are re-encoded
+	EncodingCopy      Encoding = "copy"       // Video and audio are copied
+	EncodingCopyVideo Encoding = "copy_video" // Video is copied, audio is re-encoded
+)
+
+// ProbeResult describes the source streams of a camera as reported by ffprobe
+type ProbeResult struct {
+	VideoCodec string
+	Width      int
+	Height     int
+	FPS        float64
+	AudioCodec string
+	HasAudio   bool
+	ProbedAt   time.Time
+}
+
+// ffprobeOutput is the subset of ffprobe's JSON output the agent uses
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+	} `json:"streams"`
+}
+
+// Probe inspects the video and audio streams of a source URL with ffprobe
+func Probe(ctx context.Context, url string) (*ProbeResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-rtsp_transport", "tcp",
+		"-print_format", "json",
+		"-show_streams",
+		url,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("ffprobe failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+
+	return parseProbeOutput(output)
+}
+
+// parseProbeOutput extracts the first video and audio stream from ffprobe's
+// JSON output
+func parseProbeOutput(output []byte) (*ProbeResult, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	result := &ProbeResult{ProbedAt: time.Now()}
+	for _, s := range probe.Streams {
+		switch s.CodecType {
+		case "video":
+			if result.VideoCodec != "" {
+				continue
+			}
+			result.VideoCodec = s.CodecName
+			result.Width = s.Width
+			result.Height = s.Height
+			result.FPS = parseFrameRate(s.AvgFrameRate)
+			if result.FPS == 0 {
+				result.FPS = parseFrameRate(s.RFrameRate)
+			}
+		case "audio":
+			if result.HasAudio {
+				continue
+			}
+			result.HasAudio = true
+			result.AudioCodec = s.CodecName
+		}
+	}
+
+	if result.VideoCodec == "" {
+		return nil, fmt.Errorf("source has no video stream")
+	}
+	return result, nil
+}
+
+// parseFrameRate parses an ffprobe frame rate such as "25/1" or "30000/1001"
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		v, _ := strconv.ParseFloat(rate, 64)
+		return v
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// selectEncoding decides how to encode a source in the given stream mode.
+// Passthrough copies H.264 video and AAC audio, which FLV carries as is, and
+// re-encodes only what it cannot copy.
+func selectEncoding(mode string, probe *ProbeResult) Encoding {
+	if mode != config.StreamModePassthrough || probe == nil || probe.VideoCodec != "h264" {
+		return EncodingTranscode
+	}
+	if probe.HasAudio && probe.AudioCodec != "aac" {
+		return EncodingCopyVideo
+	}
+	return EncodingCopy
+}
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/cctv-agent/config"
+)
+
+// probeOutput returns ffprobe JSON output for a camera with the given codecs
+func probeOutput(video, audio string) string {
+	out := `{"streams": [{"codec_type": "video", "codec_name": "` + video + `", "width": 1920, "height": 1080, "avg_frame_rate": "0/0", "r_frame_rate": "25/1"}`
+	if audio != "" {
+		out += `, {"codec_type": "audio", "codec_name": "` + audio + `"}`
+	}
+	return out + `]}`
+}
+
+func TestSelectEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		output string // ffprobe output, empty for a failed probe
+		want   Encoding
+	}{
+		{"H.264 and AAC", config.StreamModePassthrough, probeOutput("h264", "aac"), EncodingCopy},
+		{"H.264 without audio", config.StreamModePassthrough, probeOutput("h264", ""), EncodingCopy},
+		{"H.264 and G.711", config.StreamModePassthrough, probeOutput("h264", "pcm_alaw"), EncodingCopyVideo},
+		{"HEVC", config.StreamModePassthrough, probeOutput("hevc", "aac"), EncodingTranscode},
+		{"failed probe", config.StreamModePassthrough, "", EncodingTranscode},
+		{"transcode mode", config.StreamModeTranscode, probeOutput("h264", "aac"), EncodingTranscode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var probe *ProbeResult
+			if tt.output != "" {
+				var err error
+				probe, err = parseProbeOutput([]byte(tt.output))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := selectEncoding(tt.mode, probe); got != tt.want {
+				t.Errorf("encoding = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProbeOutput(t *testing.T) {
+	output := `{"streams": [
+		{"codec_type": "data", "codec_name": "onvif"},
+		{"codec_type": "video", "codec_name": "h264", "width": 2560, "height": 1440, "avg_frame_rate": "0/0", "r_frame_rate": "30000/1001"},
+		{"codec_type": "video", "codec_name": "mjpeg", "width": 640, "height": 480, "avg_frame_rate": "5/1"},
+		{"codec_type": "audio", "codec_name": "pcm_mulaw"},
+		{"codec_type": "audio", "codec_name": "aac"}
+	]}`
+
+	probe, err := parseProbeOutput([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first video and audio streams are used
+	if probe.VideoCodec != "h264" || probe.Width != 2560 || probe.Height != 1440 {
+		t.Errorf("video = %s %dx%d, want h264 2560x1440", probe.VideoCodec, probe.Width, probe.Height)
+	}
+	if !probe.HasAudio || probe.AudioCodec != "pcm_mulaw" {
+		t.Errorf("audio = %q (present: %v), want pcm_mulaw", probe.AudioCodec, probe.HasAudio)
+	}
+	// An unknown average frame rate falls back to the real base frame rate
+	if probe.FPS < 29.97 || probe.FPS > 29.98 {
+		t.Errorf("fps = %v, want 29.97", probe.FPS)
+	}
+	if probe.ProbedAt.IsZero() {
+		t.Error("probe time not set")
+	}
+}
+
+func TestParseProbeOutputErrors(t *testing.T) {
+	for _, output := range []string{
+		`not json`,
+		`{"streams": []}`,
+		`{"streams": [{"codec_type": "audio", "codec_name": "aac"}]}`,
+	} {
+		if _, err := parseProbeOutput([]byte(output)); err == nil {
+			t.Errorf("%s: expected an error", output)
+		}
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	tests := []struct {
+		rate string
+		want float64
+	}{
+		{"25/1", 25},
+		{"30000/1001", 30000.0 / 1001},
+		{"0/0", 0},
+		{"15", 15},
+		{"", 0},
+		{"abc/1", 0},
+		{"25/x", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseFrameRate(tt.rate); got != tt.want {
+			t.Errorf("parseFrameRate(%q) = %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
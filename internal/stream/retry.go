@@ -0,0 +1,74 @@
+package stream
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"github.com/cctv-agent/config"
+)
+
+// stableRunDuration is how long a stream must run before a failure no longer
+// counts towards the previous ones
+const stableRunDuration = time.Minute
+
+// RetryPolicy decides how long to wait before restarting a failed stream.
+// Delays grow exponentially from BaseDelay up to MaxDelay with jitter. After
+// MaxFailures consecutive failures the circuit opens and every further
+// attempt waits Cooldown. A negative MaxFailures retries forever without
+// opening the circuit.
+type RetryPolicy struct {
+	MaxFailures int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Cooldown    time.Duration
+}
+
+// RetryState is the state of a stream's retry policy
+type RetryState struct {
+	Failures    int
+	NextAttempt time.Time
+	CircuitOpen bool
+}
+
+// NewRetryPolicy creates the retry policy configured for a camera
+func NewRetryPolicy(camera *config.CameraConfig) RetryPolicy {
+	return RetryPolicy{
+		MaxFailures: camera.RetryCount,
+		BaseDelay:   camera.RetryDelay,
+		MaxDelay:    camera.RetryMaxDelay,
+		Cooldown:    camera.RetryCooldown,
+	}
+}
+
+// Next returns the delay before the next attempt after the given number of
+// consecutive failures and whether the circuit is open
+func (p RetryPolicy) Next(failures int) (time.Duration, bool) {
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.Cooldown, true
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	// Spread restarts of cameras that failed together by +/-20%
+	jitter := 0.8 + 0.4*rand.Float64()
+	return time.Duration(float64(delay) * jitter), false
+}
+
+// countFailures returns the number of consecutive failures after a run that
+// lasted ran and ended with err. A failure after a stable run does not
+// continue the previous ones, and a run ending without error resets them.
+func countFailures(failures int, ran time.Duration, err error) int {
+	if err == nil {
+		return 0
+	}
+	if ran >= stableRunDuration {
+		failures = 0
+	}
+	return failures + 1
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNext(t *testing.T) {
+	policy := RetryPolicy{
+		MaxFailures: 6,
+		BaseDelay:   time.Second,
+		MaxDelay:    10 * time.Second,
+		Cooldown:    5 * time.Minute,
+	}
+
+	tests := []struct {
+		name     string
+		failures int
+		delay    time.Duration // Before jitter
+		open     bool
+	}{
+		{"first failure", 1, time.Second, false},
+		{"second failure", 2, 2 * time.Second, false},
+		{"third failure", 3, 4 * time.Second, false},
+		{"fourth failure", 4, 8 * time.Second, false},
+		{"capped at max delay", 5, 10 * time.Second, false},
+		{"circuit opens", 6, 5 * time.Minute, true},
+		{"circuit stays open", 9, 5 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Jitter is random, so sample it
+			for i := 0; i < 100; i++ {
+				delay, open := policy.Next(tt.failures)
+				if open != tt.open {
+					t.Fatalf("circuit open = %v, want %v", open, tt.open)
+				}
+				if tt.open {
+					if delay != tt.delay {
+						t.Fatalf("delay = %v, want the cooldown %v", delay, tt.delay)
+					}
+					continue
+				}
+				min, max := tt.delay*8/10, tt.delay*12/10
+				if delay < min || delay > max {
+					t.Fatalf("delay = %v, want %v +/-20%%", delay, tt.delay)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyNeverOpens(t *testing.T) {
+	for _, maxFailures := range []int{0, -1} {
+		policy := RetryPolicy{MaxFailures: maxFailures, BaseDelay: time.Second, MaxDelay: time.Minute}
+		delay, open := policy.Next(1000)
+		if open {
+			t.Errorf("MaxFailures %d: circuit opened", maxFailures)
+		}
+		if delay < 48*time.Second || delay > 72*time.Second {
+			t.Errorf("MaxFailures %d: delay = %v, want the max delay +/-20%%", maxFailures, delay)
+		}
+	}
+}
+
+func TestCountFailures(t *testing.T) {
+	failed := errors.New("FFmpeg process exited")
+
+	tests := []struct {
+		name     string
+		failures int
+		ran      time.Duration
+		err      error
+		want     int
+	}{
+		{"first failure", 0, time.Second, failed, 1},
+		{"consecutive failure", 3, time.Second, failed, 4},
+		{"failure after a stable run", 3, stableRunDuration, failed, 1},
+		{"ended without error", 3, time.Second, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countFailures(tt.failures, tt.ran, tt.err); got != tt.want {
+				t.Errorf("failures = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
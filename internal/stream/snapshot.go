@@ -0,0 +1,141 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// snapshotTimeout bounds how long capturing a snapshot may take
+	snapshotTimeout = 15 * time.Second
+
+	// maxSnapshotSize bounds the size of a snapshot image
+	maxSnapshotSize = 10 << 20
+
+	// maxConcurrentSnapshots bounds the snapshots captured at once, as each
+	// FFmpeg frame grab decodes a stream
+	maxConcurrentSnapshots = 2
+)
+
+// Snapshot sources
+const (
+	SnapshotSourceONVIF  = "onvif"
+	SnapshotSourceFFmpeg = "ffmpeg"
+)
+
+// Snapshot is a JPEG still image of a camera
+type Snapshot struct {
+	Image   []byte
+	Source  string
+	TakenAt time.Time
+}
+
+// Snapshot captures a JPEG image of a camera from its ONVIF snapshot URI,
+// falling back to grabbing a frame of its RTSP stream with FFmpeg
+func (m *Manager) Snapshot(cameraID string) (*Snapshot, error) {
+	camera, err := m.getConfig().GetCameraByID(cameraID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, snapshotTimeout)
+	defer cancel()
+
+	select {
+	case m.snapshotSem <- struct{}{}:
+		defer func() { <-m.snapshotSem }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out waiting for another snapshot: %w", ctx.Err())
+	}
+
+	urls, _ := m.GetSourceURLs(cameraID)
+	if urls.Snapshot != "" {
+		image, err := fetchSnapshot(ctx, urls.Snapshot, camera.Username, camera.Password)
+		if err == nil {
+			return &Snapshot{Image: image, Source: SnapshotSourceONVIF, TakenAt: time.Now()}, nil
+		}
+		m.logger.Warn("Failed to fetch ONVIF snapshot, grabbing a frame",
+			"camera_id", cameraID,
+			"error", err)
+	}
+
+	sourceURL := m.SourceURL(camera)
+	if sourceURL == "" {
+		return nil, fmt.Errorf("camera has no RTSP URL: %s", cameraID)
+	}
+	image, err := grabFrame(ctx, sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Image: image, Source: SnapshotSourceFFmpeg, TakenAt: time.Now()}, nil
+}
+
+// fetchSnapshot downloads a JPEG from a camera's snapshot URI using basic
+// authentication
+func fetchSnapshot(ctx context.Context, uri, username, password string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot URI: %w", err)
+	}
+	if req.URL.User == nil && username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapshot request failed: %s", resp.Status)
+	}
+
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return checkJPEG(image)
+}
+
+// grabFrame decodes a single frame of a stream into a JPEG with FFmpeg
+func grabFrame(ctx context.Context, url string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-loglevel", "error",
+		"-rtsp_transport", "tcp",
+		"-i", url,
+		"-frames:v", "1",
+		"-f", "image2",
+		"-c:v", "mjpeg",
+		"-q:v", "3",
+		"pipe:1",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("FFmpeg frame grab failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("FFmpeg frame grab failed: %w", err)
+	}
+	return checkJPEG(stdout.Bytes())
+}
+
+// checkJPEG verifies that an image is a JPEG of acceptable size
+func checkJPEG(image []byte) ([]byte, error) {
+	if len(image) > maxSnapshotSize {
+		return nil, fmt.Errorf("snapshot exceeds %d bytes", maxSnapshotSize)
+	}
+	if !bytes.HasPrefix(image, []byte{0xFF, 0xD8}) {
+		return nil, fmt.Errorf("snapshot is not a JPEG image")
+	}
+	return image, nil
+}
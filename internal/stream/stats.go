@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stats are the encoding statistics FFmpeg reports for a stream
+type Stats struct {
+	Frames     uint64
+	FPS        float64
+	Bitrate    float64 // kbit/s
+	TotalSize  int64   // bytes
+	OutTime    time.Duration
+	DupFrames  uint64
+	DropFrames uint64
+	Speed      float64
+	UpdatedAt  time.Time
+}
+
+// progressParser accumulates the key=value lines FFmpeg writes with
+// -progress into Stats. A block ends with a "progress" line.
+type progressParser struct {
+	pending Stats
+}
+
+// parseLine consumes a progress line and returns the completed stats when
+// the line ends a block
+func (p *progressParser) parseLine(line string) (Stats, bool) {
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return Stats{}, false
+	}
+	value = strings.TrimSpace(value)
+
+	switch key {
+	case "frame":
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			p.pending.Frames = v
+		}
+	case "fps":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			p.pending.FPS = v
+		}
+	case "bitrate":
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "kbits/s"), 64); err == nil {
+			p.pending.Bitrate = v
+		}
+	case "total_size":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			p.pending.TotalSize = v
+		}
+	case "out_time_us":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			p.pending.OutTime = time.Duration(v) * time.Microsecond
+		}
+	case "dup_frames":
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			p.pending.DupFrames = v
+		}
+	case "drop_frames":
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			p.pending.DropFrames = v
+		}
+	case "speed":
+		if v, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64); err == nil {
+			p.pending.Speed = v
+		}
+	case "progress":
+		stats := p.pending
+		stats.UpdatedAt = time.Now()
+		return stats, true
+	}
+
+	return Stats{}, false
+}
@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// progressTranscript is FFmpeg -progress pipe:1 output for the first two
+// reports of a stream
+const progressTranscript = `frame=0
+fps=0.00
+stream_0_0_q=0.0
+bitrate=N/A
+total_size=44
+out_time_us=0
+out_time_ms=0
+out_time=00:00:00.000000
+dup_frames=0
+drop_frames=0
+speed=N/A
+progress=continue
+frame=148
+fps=24.97
+stream_0_0_q=29.0
+bitrate= 812.4kbits/s
+total_size=603148
+out_time_us=5939000
+out_time_ms=5939000
+out_time=00:00:05.939000
+dup_frames=2
+drop_frames=1
+speed=1.01x
+progress=continue
+`
+
+func TestProgressParser(t *testing.T) {
+	var parser progressParser
+	var reports []Stats
+	for _, line := range strings.Split(progressTranscript, "\n") {
+		if stats, ok := parser.parseLine(line); ok {
+			reports = append(reports, stats)
+		}
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want one per progress line", len(reports))
+	}
+
+	first := reports[0]
+	if first.Frames != 0 || first.Bitrate != 0 || first.Speed != 0 || first.TotalSize != 44 {
+		t.Errorf("first report = %+v, want no frames and unknown bitrate and speed", first)
+	}
+
+	got := reports[1]
+	want := Stats{
+		Frames:     148,
+		FPS:        24.97,
+		Bitrate:    812.4,
+		TotalSize:  603148,
+		OutTime:    5939 * time.Millisecond,
+		DupFrames:  2,
+		DropFrames: 1,
+		Speed:      1.01,
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Error("report time not set")
+	}
+	got.UpdatedAt = time.Time{}
+	if got != want {
+		t.Errorf("second report = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestProgressParserIncompleteBlock(t *testing.T) {
+	var parser progressParser
+	for _, line := range []string{"frame=10", "fps=25.00", "garbage", ""} {
+		if _, ok := parser.parseLine(line); ok {
+			t.Fatalf("%q completed a report", line)
+		}
+	}
+
+	stats, ok := parser.parseLine("progress=end")
+	if !ok {
+		t.Fatal("progress=end did not complete the report")
+	}
+	if stats.Frames != 10 || stats.FPS != 25 {
+		t.Errorf("report = %+v, want 10 frames at 25 fps", stats)
+	}
+}
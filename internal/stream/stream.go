@@ -3,9 +3,12 @@ package stream
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,57 +17,115 @@ import (
 	"github.com/cctv-agent/internal/logger"
 )
 
+// ErrStalled is returned by Start when FFmpeg was killed for not making
+// progress
+var ErrStalled = errors.New("stream stalled")
+
 // Stream represents a single camera stream
 type Stream struct {
-	camera     *config.CameraConfig
-	config     *config.Config
-	logger     logger.Logger
-	cmd        *exec.Cmd
-	status     StreamStatus
-	statusMu   sync.RWMutex
-	cancelFunc context.CancelFunc
-	startTime  time.Time
-	lastError  error
+	camera         *config.CameraConfig
+	config         *config.Config
+	logger         logger.Logger
+	cmd            *exec.Cmd
+	process        *os.Process
+	status         StreamStatus
+	statusMu       sync.RWMutex
+	cancelFunc     context.CancelFunc
+	startTime      time.Time
+	lastError      error
+	retry          RetryState
+	starts         int
+	lastTransition time.Time
+	stats          Stats
+	probe          *ProbeResult
+	encoding       Encoding
+	outputs        []OutputStatus
+	lastProgress   time.Time
+	stallErr       error
+	stopCh         chan struct{}
+	done           chan struct{}
+	onStatusChange func(cameraID string, status StreamStatus, errorMsg string)
+}
+
+// StreamInfo is a point-in-time snapshot of a stream's state
+type StreamInfo struct {
+	CameraID       string
+	Status         StreamStatus
+	Uptime         time.Duration
+	LastError      string
+	RetryCount     int
+	NextRetry      time.Time
+	CircuitOpen    bool
+	Restarts       int
+	LastTransition time.Time
+	Stats          Stats
+	Probe          *ProbeResult
+	Encoding       Encoding
+	Outputs        []OutputStatus
 }
 
 // NewStream creates a new stream instance
 func NewStream(camera *config.CameraConfig, cfg *config.Config, log logger.Logger) *Stream {
 	return &Stream{
-		camera: camera,
-		config: cfg,
-		logger: log,
-		status: StatusDisconnected,
+		camera:  camera,
+		config:  cfg,
+		logger:  log,
+		status:  StatusDisconnected,
+		outputs: newOutputStatuses(cfg.CameraOutputs(camera)),
 	}
 }
 
 // Start starts the stream
 func (s *Stream) Start(ctx context.Context) error {
+	// Create context for this stream, released when this run ends
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	s.statusMu.Lock()
 	if s.status == StatusConnected || s.status == StatusConnecting {
 		s.statusMu.Unlock()
 		return fmt.Errorf("stream already running")
 	}
+	// Stop closes stopCh before cancelling, under the same lock, so a stream
+	// stopped before its cancel function is set must not start at all
+	if s.stopped() {
+		s.statusMu.Unlock()
+		return nil
+	}
 	s.status = StatusConnecting
-	s.statusMu.Unlock()
-
-	// Create context for this stream
-	streamCtx, cancel := context.WithCancel(ctx)
+	s.starts++
+	s.stats = Stats{}
 	s.cancelFunc = cancel
+	s.statusMu.Unlock()
 
 	// Build FFmpeg command
-	cmd := s.buildFFmpegCommand(streamCtx)
+	encoding := s.selectEncoding(streamCtx)
+	if streamCtx.Err() != nil {
+		s.setStatus(StatusDisconnected)
+		return nil
+	}
+	cmd, err := s.buildFFmpegCommand(streamCtx, encoding)
+	if err != nil {
+		s.setLastError(err)
+		s.setStatus(StatusError)
+		return fmt.Errorf("failed to build FFmpeg command: %w", err)
+	}
+	s.statusMu.Lock()
 	s.cmd = cmd
+	s.statusMu.Unlock()
 
 	// Create pipes for stdout and stderr
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		s.setStatus(StatusError)
+		s.setLastError(err)
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		s.setStatus(StatusError)
+		s.setLastError(err)
 		return fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
@@ -72,29 +133,49 @@ func (s *Stream) Start(ctx context.Context) error {
 	s.logger.Info("Starting FFmpeg stream", "camera_id", s.camera.ID)
 	if err := cmd.Start(); err != nil {
 		s.setStatus(StatusError)
+		s.setLastError(err)
 		return fmt.Errorf("failed to start FFmpeg: %w", err)
 	}
 
+	s.statusMu.Lock()
 	s.startTime = time.Now()
+	s.lastProgress = s.startTime
+	s.stallErr = nil
+	s.retry.NextAttempt = time.Time{}
+	s.retry.CircuitOpen = false
+	s.process = cmd.Process
+	s.setOutputStates(OutputActive, true)
+	s.statusMu.Unlock()
 	s.setStatus(StatusConnected)
 
-	// Monitor stdout in goroutine
-	go s.monitorOutput(stdout, "stdout")
+	// Parse progress reports from stdout in goroutine
+	go s.monitorProgress(stdout)
 
 	// Monitor stderr in goroutine
 	go s.monitorOutput(stderr, "stderr")
 
 	// Wait for process to complete
 	err = cmd.Wait()
-	
+
+	s.statusMu.Lock()
+	stallErr := s.stallErr
+	s.process = nil
+	s.setOutputStates(OutputStopped, false)
+	s.statusMu.Unlock()
+
+	// A stalled stream keeps its status until it is started again
+	if stallErr != nil {
+		return stallErr
+	}
+
 	s.setStatus(StatusDisconnected)
-	
+
 	if err != nil {
 		if streamCtx.Err() == context.Canceled {
 			s.logger.Info("Stream stopped by context cancellation", "camera_id", s.camera.ID)
 			return nil
 		}
-		s.lastError = err
+		s.setLastError(err)
 		s.logger.Error("FFmpeg process exited with error", "camera_id", s.camera.ID, "error", err)
 		return fmt.Errorf("FFmpeg process exited: %w", err)
 	}
@@ -102,71 +183,138 @@ func (s *Stream) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop stops the stream
+// Stop stops the stream and ends its supervision
 func (s *Stream) Stop() {
 	s.logger.Info("Stopping stream", "camera_id", s.camera.ID)
-	
-	if s.cancelFunc != nil {
-		s.cancelFunc()
+
+	s.statusMu.Lock()
+	if s.stopCh != nil && !s.stopped() {
+		close(s.stopCh)
+	}
+	cancel := s.cancelFunc
+	running := s.process != nil
+	s.statusMu.Unlock()
+
+	if cancel != nil {
+		cancel()
 	}
 
-	if s.cmd != nil && s.cmd.Process != nil {
+	if running {
 		// Give FFmpeg time to exit gracefully
 		time.Sleep(2 * time.Second)
-		
-		// Force kill if still running
-		if s.cmd.ProcessState == nil {
+
+		// Force kill if still running. The process is cleared once FFmpeg
+		// has been waited for.
+		s.statusMu.RLock()
+		process := s.process
+		s.statusMu.RUnlock()
+		if process != nil {
 			s.logger.Warn("Force killing FFmpeg process", "camera_id", s.camera.ID)
-			s.cmd.Process.Kill()
+			process.Kill()
 		}
 	}
 
 	s.setStatus(StatusDisconnected)
 }
 
+// selectEncoding probes the source of a passthrough stream and records how
+// it will be encoded. Sources that cannot be probed are transcoded.
+func (s *Stream) selectEncoding(ctx context.Context) Encoding {
+	var probe *ProbeResult
+	if s.camera.StreamMode == config.StreamModePassthrough {
+		var err error
+		probe, err = Probe(ctx, s.camera.RTSPUrl)
+		if err != nil {
+			s.logger.Warn("Failed to probe source, transcoding", "camera_id", s.camera.ID, "error", err)
+		}
+	}
+
+	encoding := selectEncoding(s.camera.StreamMode, probe)
+	if probe != nil {
+		s.logger.Info("Probed source",
+			"camera_id", s.camera.ID,
+			"video_codec", probe.VideoCodec,
+			"resolution", fmt.Sprintf("%dx%d", probe.Width, probe.Height),
+			"fps", probe.FPS,
+			"audio_codec", probe.AudioCodec,
+			"encoding", encoding)
+	}
+
+	s.statusMu.Lock()
+	s.probe = probe
+	s.encoding = encoding
+	s.statusMu.Unlock()
+
+	return encoding
+}
+
 // buildFFmpegCommand builds the FFmpeg command
-func (s *Stream) buildFFmpegCommand(ctx context.Context) *exec.Cmd {
-	rtmpURL := fmt.Sprintf("rtmp://%s:%d/%s/%s",
-		s.config.RTMP.Host,
-		s.config.RTMP.Port,
-		s.config.RTMP.AppName,
-		s.camera.StreamID,
-	)
+func (s *Stream) buildFFmpegCommand(ctx context.Context, encoding Encoding) (*exec.Cmd, error) {
+	ffmpeg, err := s.config.CameraFFmpeg(s.camera)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := s.config.CameraOutputs(s.camera)
+	if err := prepareOutputs(outputs); err != nil {
+		return nil, err
+	}
 
 	args := []string{
+		"-progress", "pipe:1",
+		"-nostats",
 		"-rtsp_transport", "tcp",
 		"-i", s.camera.RTSPUrl,
-		"-c:v", s.config.FFmpeg.VideoCodec,
-		"-preset", s.config.FFmpeg.Preset,
-		"-tune", s.config.FFmpeg.Tune,
-		"-crf", fmt.Sprintf("%d", s.config.FFmpeg.CRF),
-		"-maxrate", s.config.FFmpeg.MaxRate,
-		"-bufsize", s.config.FFmpeg.BufSize,
-		"-c:a", s.config.FFmpeg.AudioCodec,
-		"-b:a", s.config.FFmpeg.AudioBitrate,
-		"-ar", fmt.Sprintf("%d", s.config.FFmpeg.AudioRate),
-		"-f", "flv",
+	}
+
+	if encoding == EncodingTranscode {
+		args = append(args,
+			"-c:v", ffmpeg.VideoCodec,
+			"-preset", ffmpeg.Preset,
+			"-tune", ffmpeg.Tune,
+			"-crf", fmt.Sprintf("%d", ffmpeg.CRF),
+			"-maxrate", ffmpeg.MaxRate,
+			"-bufsize", ffmpeg.BufSize,
+		)
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+
+	if encoding == EncodingCopy {
+		args = append(args, "-c:a", "copy")
+	} else {
+		args = append(args,
+			"-c:a", ffmpeg.AudioCodec,
+			"-b:a", ffmpeg.AudioBitrate,
+			"-ar", fmt.Sprintf("%d", ffmpeg.AudioRate),
+		)
+	}
+
+	// Encoders must emit global headers for the muxers behind tee, including
+	// the audio encoder when only the video is copied
+	if encoding != EncodingCopy && len(outputs) > 1 {
+		args = append(args, "-flags", "+global_header")
 	}
 
 	// Add extra arguments if configured
-	if s.config.FFmpeg.ExtraArgs != "" {
-		extraArgs := strings.Fields(s.config.FFmpeg.ExtraArgs)
+	if ffmpeg.ExtraArgs != "" {
+		extraArgs := strings.Fields(ffmpeg.ExtraArgs)
 		args = append(args, extraArgs...)
 	}
 
 	// Add log level
-	if s.config.FFmpeg.LogLevel != "" {
-		args = append([]string{"-loglevel", s.config.FFmpeg.LogLevel}, args...)
+	if ffmpeg.LogLevel != "" {
+		args = append([]string{"-loglevel", ffmpeg.LogLevel}, args...)
 	}
 
-	// Add RTMP URL
-	args = append(args, rtmpURL)
+	// Add outputs
+	args = append(args, outputArgs(outputs)...)
 
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	
+
 	s.logger.Debug("FFmpeg command", "args", strings.Join(args, " "))
-	
-	return cmd
+
+	return cmd, nil
 }
 
 // monitorOutput monitors FFmpeg output
@@ -176,7 +324,11 @@ func (s *Stream) monitorOutput(pipe io.ReadCloser, source string) {
 	scanner := bufio.NewScanner(pipe)
 	for scanner.Scan() {
 		line := scanner.Text()
-		
+
+		if index, reason, ok := parseTeeFailure(line); ok {
+			s.setOutputFailed(index, reason)
+		}
+
 		// Log based on content
 		if strings.Contains(line, "error") || strings.Contains(line, "Error") {
 			s.logger.Error("FFmpeg error", "camera_id", s.camera.ID, "source", source, "message", line)
@@ -192,6 +344,123 @@ func (s *Stream) monitorOutput(pipe io.ReadCloser, source string) {
 	}
 }
 
+// monitorProgress parses the progress reports FFmpeg writes to stdout
+func (s *Stream) monitorProgress(pipe io.ReadCloser) {
+	defer pipe.Close()
+
+	var parser progressParser
+	scanner := bufio.NewScanner(pipe)
+	for scanner.Scan() {
+		if stats, ok := parser.parseLine(scanner.Text()); ok {
+			s.statusMu.Lock()
+			if stats.Frames > s.stats.Frames || stats.OutTime > s.stats.OutTime {
+				s.lastProgress = stats.UpdatedAt
+			}
+			s.stats = stats
+			s.statusMu.Unlock()
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		s.logger.Error("Error reading FFmpeg progress", "camera_id", s.camera.ID, "error", err)
+	}
+}
+
+// stalledFor returns how long a connected stream has not made progress
+func (s *Stream) stalledFor(now time.Time) time.Duration {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	if s.status != StatusConnected || s.process == nil || s.stallErr != nil {
+		return 0
+	}
+	return now.Sub(s.lastProgress)
+}
+
+// killStalled marks the stream as stalled and kills FFmpeg, making Start
+// return ErrStalled
+func (s *Stream) killStalled(idle time.Duration) {
+	s.statusMu.Lock()
+	process := s.process
+	if process == nil || s.stallErr != nil {
+		s.statusMu.Unlock()
+		return
+	}
+	s.stallErr = fmt.Errorf("%w: no progress for %s", ErrStalled, idle.Round(time.Second))
+	s.lastError = s.stallErr
+	s.statusMu.Unlock()
+
+	s.setStatus(StatusStalled)
+
+	if err := process.Kill(); err != nil {
+		s.logger.Error("Failed to kill stalled FFmpeg process", "camera_id", s.camera.ID, "error", err)
+	}
+}
+
+// stallTimeout returns how long the stream may go without progress before
+// it is restarted
+func (s *Stream) stallTimeout() time.Duration {
+	ffmpeg, err := s.config.CameraFFmpeg(s.camera)
+	if err != nil {
+		return s.config.FFmpeg.StallTimeout
+	}
+	return ffmpeg.StallTimeout
+}
+
+// newOutputStatuses returns the initial status of a stream's outputs
+func newOutputStatuses(outputs []config.OutputConfig) []OutputStatus {
+	statuses := make([]OutputStatus, len(outputs))
+	for i, output := range outputs {
+		statuses[i] = OutputStatus{
+			Name:  output.Name,
+			Type:  output.Type,
+			State: OutputStopped,
+		}
+	}
+	return statuses
+}
+
+// setOutputStates moves the outputs to state. Failed outputs keep their
+// error unless reset is set. Callers must hold statusMu.
+func (s *Stream) setOutputStates(state OutputState, reset bool) {
+	now := time.Now()
+	for i := range s.outputs {
+		output := &s.outputs[i]
+		if output.State == OutputFailed && !reset {
+			continue
+		}
+		if output.State != state {
+			output.Since = now
+		}
+		output.State = state
+		output.Error = ""
+	}
+}
+
+// setOutputFailed marks an output dropped by FFmpeg as failed; the others
+// keep running until the stream is restarted
+func (s *Stream) setOutputFailed(index int, reason string) {
+	s.statusMu.Lock()
+	if index < 0 || index >= len(s.outputs) {
+		s.statusMu.Unlock()
+		return
+	}
+	output := &s.outputs[index]
+	output.State = OutputFailed
+	output.Error = reason
+	output.Since = time.Now()
+	name := output.Name
+	s.statusMu.Unlock()
+
+	s.logger.Warn("Stream output failed", "camera_id", s.camera.ID, "output", name, "error", reason)
+}
+
+// Stats returns the latest encoding statistics of the stream
+func (s *Stream) Stats() Stats {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.stats
+}
+
 // GetStatus returns the current stream status
 func (s *Stream) GetStatus() StreamStatus {
 	s.statusMu.RLock()
@@ -199,16 +468,37 @@ func (s *Stream) GetStatus() StreamStatus {
 	return s.status
 }
 
-// setStatus sets the stream status
+// setStatus sets the stream status and publishes transitions
 func (s *Stream) setStatus(status StreamStatus) {
 	s.statusMu.Lock()
-	defer s.statusMu.Unlock()
+	changed := s.status != status
+	if changed {
+		s.lastTransition = time.Now()
+	}
 	s.status = status
+	errorMsg := ""
+	if s.lastError != nil && (status == StatusError || status == StatusReconnecting || status == StatusStalled) {
+		errorMsg = s.lastError.Error()
+	}
+	notify := s.onStatusChange
+	s.statusMu.Unlock()
+
+	if changed && notify != nil {
+		notify(s.camera.ID, status, errorMsg)
+	}
 }
 
 // GetUptime returns the stream uptime
 func (s *Stream) GetUptime() time.Duration {
-	if s.startTime.IsZero() {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.uptime()
+}
+
+// uptime returns the time since FFmpeg started while the stream is
+// connected; callers must hold statusMu
+func (s *Stream) uptime() time.Duration {
+	if s.startTime.IsZero() || s.status != StatusConnected {
 		return 0
 	}
 	return time.Since(s.startTime)
@@ -216,9 +506,110 @@ func (s *Stream) GetUptime() time.Duration {
 
 // GetLastError returns the last error
 func (s *Stream) GetLastError() error {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
 	return s.lastError
 }
 
+// setLastError records the last error
+func (s *Stream) setLastError(err error) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.lastError = err
+}
+
+// GetRetryCount returns the number of consecutive failed attempts
+func (s *Stream) GetRetryCount() int {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.retry.Failures
+}
+
+// GetRetryState returns the state of the stream's retry policy
+func (s *Stream) GetRetryState() RetryState {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.retry
+}
+
+// setRetryState records the state of the stream's retry policy
+func (s *Stream) setRetryState(state RetryState) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.retry = state
+}
+
+// beginSupervision prepares the stream to be run by a supervisor and
+// returns the channels signalling a stop and the end of supervision
+func (s *Stream) beginSupervision() (stop <-chan struct{}, done chan struct{}) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	s.stopCh = make(chan struct{})
+	s.done = make(chan struct{})
+	return s.stopCh, s.done
+}
+
+// stopped reports whether Stop has ended the stream's supervision; callers
+// must hold statusMu
+func (s *Stream) stopped() bool {
+	if s.stopCh == nil {
+		return false
+	}
+	select {
+	case <-s.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitSupervision waits until the stream is no longer supervised
+func (s *Stream) waitSupervision() {
+	s.statusMu.RLock()
+	done := s.done
+	s.statusMu.RUnlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// restarts returns how often FFmpeg was started again after the first
+// start; callers must hold statusMu
+func (s *Stream) restarts() int {
+	if s.starts == 0 {
+		return 0
+	}
+	return s.starts - 1
+}
+
+// Info returns a snapshot of the stream state
+func (s *Stream) Info() StreamInfo {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+
+	info := StreamInfo{
+		CameraID:       s.camera.ID,
+		Status:         s.status,
+		Uptime:         s.uptime(),
+		RetryCount:     s.retry.Failures,
+		NextRetry:      s.retry.NextAttempt,
+		CircuitOpen:    s.retry.CircuitOpen,
+		Restarts:       s.restarts(),
+		LastTransition: s.lastTransition,
+		Stats:          s.stats,
+		Encoding:       s.encoding,
+		Outputs:        slices.Clone(s.outputs),
+	}
+	if s.probe != nil {
+		probe := *s.probe
+		info.Probe = &probe
+	}
+	if s.lastError != nil {
+		info.LastError = s.lastError.Error()
+	}
+	return info
+}
+
 // IsRunning checks if the stream is running
 func (s *Stream) IsRunning() bool {
 	status := s.GetStatus()
@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/logger"
+)
+
+func TestBuildFFmpegCommandGlobalHeader(t *testing.T) {
+	rtmp := config.OutputConfig{Name: "rtmp", Type: config.OutputTypeRTMP, URL: "rtmp://server/live/cam1"}
+	srt := config.OutputConfig{Name: "srt", Type: config.OutputTypeSRT, URL: "srt://server:9000"}
+
+	tests := []struct {
+		name     string
+		encoding Encoding
+		outputs  []config.OutputConfig
+		want     bool
+	}{
+		{"transcode to tee", EncodingTranscode, []config.OutputConfig{rtmp, srt}, true},
+		{"copy video to tee", EncodingCopyVideo, []config.OutputConfig{rtmp, srt}, true},
+		{"copy to tee", EncodingCopy, []config.OutputConfig{rtmp, srt}, false},
+		{"transcode to one output", EncodingTranscode, []config.OutputConfig{rtmp}, false},
+		{"copy video to one output", EncodingCopyVideo, []config.OutputConfig{rtmp}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			camera := &config.CameraConfig{ID: "cam1", RTSPUrl: "rtsp://camera/stream", Outputs: tt.outputs}
+			cfg := &config.Config{
+				FFmpeg:  config.FFmpegConfig{VideoCodec: "libx264", AudioCodec: "aac"},
+				Cameras: []config.CameraConfig{*camera},
+			}
+			s := NewStream(camera, cfg, logger.NewNopLogger())
+
+			cmd, err := s.buildFFmpegCommand(context.Background(), tt.encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			i := slices.Index(cmd.Args, "+global_header")
+			if got := i > 0 && cmd.Args[i-1] == "-flags"; got != tt.want {
+				t.Errorf("global header = %v, want %v: %v", got, tt.want, cmd.Args)
+			}
+			if usesTee := slices.Contains(cmd.Args, "tee"); usesTee != (len(tt.outputs) > 1) {
+				t.Errorf("tee muxer used = %v with %d outputs", usesTee, len(tt.outputs))
+			}
+		})
+	}
+}
+
+func TestStartAfterStop(t *testing.T) {
+	camera := &config.CameraConfig{ID: "cam1", RTSPUrl: "rtsp://camera/stream"}
+	cfg := &config.Config{Cameras: []config.CameraConfig{*camera}}
+	s := NewStream(camera, cfg, logger.NewNopLogger())
+
+	s.beginSupervision()
+	s.Stop()
+
+	// A stream stopped before it starts must not launch FFmpeg, which
+	// nothing would cancel
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start after Stop = %v, want nil", err)
+	}
+	if s.starts != 0 || s.GetStatus() != StatusDisconnected {
+		t.Errorf("stream started after Stop: %d starts, status %s", s.starts, s.GetStatus())
+	}
+}
@@ -0,0 +1,156 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// healthGateInterval is how often the readiness of an upgraded agent is
+// checked until its health timeout expires
+const healthGateInterval = time.Second
+
+// ReadinessChecker reports whether the agent is ready, such as
+// health.Checker
+type ReadinessChecker interface {
+	Ready() bool
+}
+
+// pendingUpgrade records an installed upgrade until the new version has
+// passed its health gate, and how to roll it back
+type pendingUpgrade struct {
+	Version string `json:"version"`
+	Release string `json:"release,omitempty"` // Previous target of the current symlink
+	Backup  string `json:"backup,omitempty"`  // Backup of the replaced binary
+}
+
+// pendingUpgradePath returns the file recording a pending upgrade
+func (u *Updater) pendingUpgradePath() string {
+	return filepath.Join(u.opts.BaseDir, "pending-upgrade.json")
+}
+
+// savePendingUpgrade records an installed upgrade before restarting into it
+func (u *Updater) savePendingUpgrade(p pendingUpgrade) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(u.opts.BaseDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(u.pendingUpgradePath(), data, 0o644)
+}
+
+// loadPendingUpgrade returns the pending upgrade, or nil if there is none
+func (u *Updater) loadPendingUpgrade() (*pendingUpgrade, error) {
+	data, err := os.ReadFile(u.pendingUpgradePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p pendingUpgrade
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// HandleStartup should be called once the agent has started. After an
+// upgrade it waits up to the health timeout for the agent to become ready,
+// and rolls the upgrade back and restarts if it does not.
+func (u *Updater) HandleStartup(ctx context.Context, health ReadinessChecker) {
+	pending, err := u.loadPendingUpgrade()
+	if err != nil {
+		u.logger.Error("Failed to read pending upgrade", "error", err)
+		return
+	}
+	if pending == nil {
+		u.logger.Info("Updater startup check complete", "version", u.currentVersion)
+		return
+	}
+
+	// A record left by another version, e.g. after a rollback, is stale
+	if !sameVersion(pending.Version, u.currentVersion) {
+		u.logger.Warn("Discarding pending upgrade of another version",
+			"pending", pending.Version,
+			"version", u.currentVersion)
+		u.clearPendingUpgrade()
+		return
+	}
+
+	u.logger.Info("Waiting for upgraded agent to become ready",
+		"version", u.currentVersion,
+		"timeout", u.opts.HealthTimeout)
+
+	deadline := time.NewTimer(u.opts.HealthTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(healthGateInterval)
+	defer ticker.Stop()
+
+	for {
+		if health.Ready() {
+			u.logger.Info("Upgrade passed its health gate", "version", u.currentVersion)
+			u.clearPendingUpgrade()
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			// The gate runs again on the next start
+			return
+		case <-deadline.C:
+			u.logger.Error("Upgraded agent did not become ready, rolling back",
+				"version", u.currentVersion,
+				"timeout", u.opts.HealthTimeout)
+			if err := u.rollback(pending); err != nil {
+				u.logger.Error("Failed to roll back upgrade", "error", err)
+				return
+			}
+			u.clearPendingUpgrade()
+			u.restart()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// rollback restores the release or binary replaced by an upgrade
+func (u *Updater) rollback(pending *pendingUpgrade) error {
+	switch {
+	case pending.Release != "":
+		if _, err := os.Stat(pending.Release); err != nil {
+			return fmt.Errorf("previous release: %w", err)
+		}
+		return u.switchCurrent(pending.Release)
+	case pending.Backup != "":
+		return u.restoreBinary(pending.Backup)
+	default:
+		return fmt.Errorf("no previous release or backup of %s", pending.Version)
+	}
+}
+
+// clearPendingUpgrade removes the pending upgrade record
+func (u *Updater) clearPendingUpgrade() {
+	if err := os.Remove(u.pendingUpgradePath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		u.logger.Warn("Failed to remove pending upgrade record", "error", err)
+	}
+}
+
+// sameVersion compares versions, ignoring formatting such as a "v" prefix
+func sameVersion(a, b string) bool {
+	va, errA := version.NewVersion(a)
+	vb, errB := version.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return va.Equal(vb)
+}
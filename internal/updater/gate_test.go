@@ -0,0 +1,138 @@
+package updater
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cctv-agent/internal/logger"
+)
+
+// readiness is a fixed ReadinessChecker
+type readiness bool
+
+func (r readiness) Ready() bool { return bool(r) }
+
+// newGatedUpdater returns an updater running version 1.1.0 from a release
+// directory in which 1.0.0 is the previous release
+func newGatedUpdater(t *testing.T) (u *Updater, previous string, restarts *int) {
+	t.Helper()
+	base := t.TempDir()
+
+	u = NewUpdater(logger.NewNopLogger(), "1.1.0")
+	u.opts.BaseDir = base
+	u.opts.HealthTimeout = 100 * time.Millisecond
+	restarts = new(int)
+	u.restart = func() { *restarts++ }
+
+	for _, v := range []string{"1.0.0", "1.1.0"} {
+		dir := filepath.Join(base, "releases", v)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cctv-agent"), []byte(v), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous = filepath.Join(base, "releases", "1.0.0", "cctv-agent")
+	if err := u.switchCurrent(filepath.Join(base, "releases", "1.1.0", "cctv-agent")); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.savePendingUpgrade(pendingUpgrade{Version: "v1.1.0", Release: previous}); err != nil {
+		t.Fatal(err)
+	}
+	return u, previous, restarts
+}
+
+// current returns the target of the current symlink
+func current(t *testing.T, u *Updater) string {
+	t.Helper()
+	target, err := os.Readlink(filepath.Join(u.opts.BaseDir, "current"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+func TestHandleStartupReady(t *testing.T) {
+	u, previous, restarts := newGatedUpdater(t)
+	upgraded := current(t, u)
+
+	u.HandleStartup(context.Background(), readiness(true))
+
+	if target := current(t, u); target != upgraded || target == previous {
+		t.Errorf("current release = %s, want %s", target, upgraded)
+	}
+	if *restarts != 0 {
+		t.Errorf("restarted %d times", *restarts)
+	}
+	if pending, _ := u.loadPendingUpgrade(); pending != nil {
+		t.Error("pending upgrade not cleared after passing the health gate")
+	}
+}
+
+func TestHandleStartupRollsBack(t *testing.T) {
+	u, previous, restarts := newGatedUpdater(t)
+
+	start := time.Now()
+	u.HandleStartup(context.Background(), readiness(false))
+	if elapsed := time.Since(start); elapsed < u.opts.HealthTimeout {
+		t.Errorf("rolled back after %v, before the health timeout", elapsed)
+	}
+
+	if target := current(t, u); target != previous {
+		t.Errorf("current release = %s, want the previous release %s", target, previous)
+	}
+	if *restarts != 1 {
+		t.Errorf("restarted %d times, want 1", *restarts)
+	}
+	if pending, _ := u.loadPendingUpgrade(); pending != nil {
+		t.Error("pending upgrade not cleared after the rollback")
+	}
+}
+
+func TestHandleStartupRestoresBackup(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "cctv-agent")
+	backup := binary + ".backup"
+	if err := os.WriteFile(binary, []byte("new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(backup, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	u := NewUpdater(logger.NewNopLogger(), "2.0.0")
+	u.SetBinaryPath(binary)
+	u.opts.BaseDir = dir
+	u.opts.HealthTimeout = 10 * time.Millisecond
+	restarts := 0
+	u.restart = func() { restarts++ }
+	if err := u.savePendingUpgrade(pendingUpgrade{Version: "2.0.0", Backup: backup}); err != nil {
+		t.Fatal(err)
+	}
+
+	u.HandleStartup(context.Background(), readiness(false))
+
+	if data, _ := os.ReadFile(binary); string(data) != "old" || restarts != 1 {
+		t.Errorf("binary = %q after %d restarts, want the backup restored and one restart", data, restarts)
+	}
+}
+
+func TestHandleStartupStalePendingUpgrade(t *testing.T) {
+	u, _, restarts := newGatedUpdater(t)
+	upgraded := current(t, u)
+	// The previous version runs again, e.g. after a rollback
+	u.currentVersion = "1.0.0"
+
+	u.HandleStartup(context.Background(), readiness(false))
+
+	if target := current(t, u); target != upgraded || *restarts != 0 {
+		t.Errorf("stale pending upgrade acted on: current %s, %d restarts", target, *restarts)
+	}
+	if pending, _ := u.loadPendingUpgrade(); pending != nil {
+		t.Error("stale pending upgrade not cleared")
+	}
+}
@@ -32,8 +32,35 @@ type Updater struct {
 	sioClient      *socketio.Client
 	responseMap    map[string]chan *UpdateCheckResponse
 	responseMu     sync.RWMutex
+	status         Status
+	statusMu       sync.RWMutex
+	restart        func()
 }
 
+// State represents what the updater is currently doing
+type State string
+
+const (
+	StateIdle        State = "idle"
+	StateChecking    State = "checking"
+	StateDownloading State = "downloading"
+	StateInstalling  State = "installing"
+	StateRestarting  State = "restarting"
+)
+
+// Status is a snapshot of the updater state
+type Status struct {
+	State            State
+	AvailableVersion string
+	LastCheck        time.Time
+	LastError        string
+	Checks           uint64
+	Failures         uint64
+}
+
+// errNoUpdate is returned by the server check when no update is available
+var errNoUpdate = errors.New("no update available")
+
 // RunPeriodic starts a background loop to periodically check and apply updates based on options
 func (u *Updater) RunPeriodic(ctx context.Context) {
 	if !u.opts.Enabled {
@@ -49,7 +76,9 @@ func (u *Updater) RunPeriodic(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-timer.C:
-			if err := u.checkAndMaybeUpdate(ctx); err != nil {
+			err := u.checkAndMaybeUpdate(ctx)
+			u.finishCycle(err)
+			if err != nil && !errors.Is(err, errNoUpdate) {
 				u.logger.Error("Update cycle error", "error", err)
 			}
 			interval := u.opts.Interval
@@ -67,6 +96,7 @@ func (u *Updater) RunPeriodic(ctx context.Context) {
 }
 
 func (u *Updater) checkAndMaybeUpdate(ctx context.Context) error {
+	u.startCycle()
 	m, err := u.fetchManifest(ctx)
 	if err != nil {
 		if u.opts.URL == "" { // no fallback URL
@@ -95,6 +125,7 @@ func (u *Updater) checkAndMaybeUpdate(ctx context.Context) error {
 		u.logger.Info("No update available", "current", u.currentVersion)
 		return nil
 	}
+	u.setAvailableVersion(m.Version)
 
 	// download to updates dir
 	updatesDir := filepath.Join(u.opts.BaseDir, "updates")
@@ -103,6 +134,7 @@ func (u *Updater) checkAndMaybeUpdate(ctx context.Context) error {
 	}
 	staging := filepath.Join(updatesDir, m.Version+".partial")
 	final := filepath.Join(updatesDir, m.Version)
+	u.setState(StateDownloading)
 	if err := u.downloadWithResume(ctx, m.URL, staging); err != nil {
 		return fmt.Errorf("download failed: %w", err)
 	}
@@ -114,10 +146,11 @@ func (u *Updater) checkAndMaybeUpdate(ctx context.Context) error {
 			return err
 		}
 	}
+	u.setState(StateInstalling)
 	if err := u.installRelease(final, m.Version); err != nil {
 		return fmt.Errorf("install release: %w", err)
 	}
-	u.scheduleRestart()
+	u.restart()
 	return nil
 }
 
@@ -169,7 +202,7 @@ func (u *Updater) fetchManifestViaSocketIO(ctx context.Context) (*Manifest, erro
 
 		if !response.UpdateAvailable {
 			u.logger.Info("No update available via SocketIO")
-			return nil, errors.New("no update available")
+			return nil, errNoUpdate
 		}
 
 		// Convert response to Manifest format
@@ -262,8 +295,22 @@ func (u *Updater) installRelease(artifactPath, versionStr string) error {
 	if err := os.Chmod(targetBin, 0o755); err != nil {
 		return err
 	}
-	current := filepath.Join(base, "current")
-	tmp := filepath.Join(base, ".current.tmp")
+	// The replaced release is restored if the new one fails its health gate
+	previous, _ := os.Readlink(filepath.Join(base, "current"))
+	if err := u.switchCurrent(targetBin); err != nil {
+		return err
+	}
+	if err := u.savePendingUpgrade(pendingUpgrade{Version: versionStr, Release: previous}); err != nil {
+		u.logger.Warn("Failed to record pending upgrade, it will not be health gated", "error", err)
+	}
+	u.pruneOldReleases(filepath.Join(base, "releases"))
+	return nil
+}
+
+// switchCurrent atomically points the current symlink at a release binary
+func (u *Updater) switchCurrent(targetBin string) error {
+	current := filepath.Join(u.opts.BaseDir, "current")
+	tmp := filepath.Join(u.opts.BaseDir, ".current.tmp")
 	_ = os.Remove(tmp)
 	if err := os.Symlink(targetBin, tmp); err != nil {
 		return fmt.Errorf("create tmp symlink: %w", err)
@@ -272,7 +319,6 @@ func (u *Updater) installRelease(artifactPath, versionStr string) error {
 		_ = os.Remove(tmp)
 		return fmt.Errorf("rename symlink: %w", err)
 	}
-	u.pruneOldReleases(filepath.Join(base, "releases"))
 	return nil
 }
 
@@ -334,11 +380,12 @@ type UpdateCheckResponse struct {
 func NewUpdater(log logger.Logger, currentVersion string) *Updater {
 	binaryPath, _ := os.Executable()
 
-	return &Updater{
+	u := &Updater{
 		logger:         log,
 		currentVersion: currentVersion,
 		binaryPath:     binaryPath,
 		responseMap:    make(map[string]chan *UpdateCheckResponse),
+		status:         Status{State: StateIdle},
 		opts: config.UpdaterConfig{ // sensible defaults; can be overridden via ApplyConfig
 			Enabled:        true,
 			BaseDir:        "/opt/cctv-agent",
@@ -350,6 +397,8 @@ func NewUpdater(log logger.Logger, currentVersion string) *Updater {
 			AllowDowngrade: false,
 		},
 	}
+	u.restart = u.scheduleRestart
+	return u
 }
 
 // ApplyConfig sets runtime options from config.UpdaterConfig, filling in sane fallbacks
@@ -452,9 +501,13 @@ func (u *Updater) CheckForUpdate(info UpdateInfo) (bool, error) {
 }
 
 // PerformUpdate performs the update
-func (u *Updater) PerformUpdate(info UpdateInfo) error {
+func (u *Updater) PerformUpdate(info UpdateInfo) (err error) {
 	u.logger.Info("Starting update process", "version", info.Version)
 
+	u.startCycle()
+	u.setAvailableVersion(info.Version)
+	defer func() { u.finishCycle(err) }()
+
 	// Create temp directory for download
 	tempDir, err := os.MkdirTemp("", "cctv-agent-update-*")
 	if err != nil {
@@ -463,6 +516,7 @@ func (u *Updater) PerformUpdate(info UpdateInfo) error {
 	defer os.RemoveAll(tempDir)
 
 	// Download new binary
+	u.setState(StateDownloading)
 	tempBinary := filepath.Join(tempDir, "cctv-agent-new")
 	if err := u.downloadBinary(info.DownloadURL, tempBinary); err != nil {
 		return fmt.Errorf("failed to download binary: %w", err)
@@ -481,9 +535,12 @@ func (u *Updater) PerformUpdate(info UpdateInfo) error {
 	}
 
 	// Backup current binary
+	u.setState(StateInstalling)
 	backupPath := u.binaryPath + ".backup"
+	backedUp := true
 	if err := u.backupBinary(backupPath); err != nil {
 		u.logger.Warn("Failed to backup current binary", "error", err)
+		backedUp = false
 	}
 
 	// Replace binary
@@ -497,8 +554,15 @@ func (u *Updater) PerformUpdate(info UpdateInfo) error {
 
 	u.logger.Info("Update completed successfully", "version", info.Version)
 
+	// Without a backup there is nothing to roll back to
+	if backedUp {
+		if err := u.savePendingUpgrade(pendingUpgrade{Version: info.Version, Backup: backupPath}); err != nil {
+			u.logger.Warn("Failed to record pending upgrade, it will not be health gated", "error", err)
+		}
+	}
+
 	// Schedule restart
-	u.scheduleRestart()
+	u.restart()
 
 	return nil
 }
@@ -660,6 +724,7 @@ func (u *Updater) restoreBinary(backupPath string) error {
 // scheduleRestart schedules a restart of the service
 func (u *Updater) scheduleRestart() {
 	u.logger.Info("Scheduling service restart in 5 seconds", "service", u.opts.ServiceName)
+	u.setState(StateRestarting)
 
 	go func() {
 		time.Sleep(5 * time.Second)
@@ -683,10 +748,50 @@ func (u *Updater) scheduleRestart() {
 	}()
 }
 
-// HandleStartup should be called on process start to finalize pending updates
-func (u *Updater) HandleStartup() {
-	// For now, just log successful start; further health checks can be added
-	u.logger.Info("Updater startup check complete", "version", u.currentVersion)
+// GetStatus returns a snapshot of the updater state
+func (u *Updater) GetStatus() Status {
+	u.statusMu.RLock()
+	defer u.statusMu.RUnlock()
+	return u.status
+}
+
+// setState records what the updater is doing
+func (u *Updater) setState(state State) {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	u.status.State = state
+}
+
+// setAvailableVersion records the version being installed
+func (u *Updater) setAvailableVersion(v string) {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	u.status.AvailableVersion = v
+}
+
+// startCycle records the start of an update check
+func (u *Updater) startCycle() {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	u.status.State = StateChecking
+	u.status.LastCheck = time.Now()
+	u.status.Checks++
+}
+
+// finishCycle records the outcome of an update check. The updater stays in
+// the restarting state once an update has been installed.
+func (u *Updater) finishCycle(err error) {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if u.status.State != StateRestarting {
+		u.status.State = StateIdle
+	}
+	if err != nil && !errors.Is(err, errNoUpdate) {
+		u.status.LastError = err.Error()
+		u.status.Failures++
+		return
+	}
+	u.status.LastError = ""
 }
 
 // GetCurrentVersion returns the current version
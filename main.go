@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -13,40 +15,72 @@ import (
 	"time"
 
 	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/health"
+	"github.com/cctv-agent/internal/hls"
 	"github.com/cctv-agent/internal/logger"
+	"github.com/cctv-agent/internal/metrics"
 	"github.com/cctv-agent/internal/monitor"
 	"github.com/cctv-agent/internal/onvif"
+	"github.com/cctv-agent/internal/recording"
 	"github.com/cctv-agent/internal/socketio"
 	"github.com/cctv-agent/internal/stream"
 	"github.com/cctv-agent/internal/updater"
 	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
 	version           = "1.0.0"
 	defaultConfigPath = "/etc/cctv-agent/config.json"
+
+	// statusCoalesceWindow bounds how long status transitions are batched
+	// before being pushed to the server
+	statusCoalesceWindow = 250 * time.Millisecond
 )
 
 // Application represents the main application
 type Application struct {
 	config        *config.Config
+	configPath    string
+	configMu      sync.RWMutex
+	reloadMu      sync.Mutex
+	updateMu      sync.Mutex
 	logger        logger.Logger
 	streamManager *stream.Manager
+	recorder      *recording.Manager
 	onvifCtrl     *onvif.Controller
+	onvifRetries  map[string]*onvifRetry
+	onvifMu       sync.Mutex
 	sioClient     *socketio.Client
+	results       eventEmitter
 	updater       *updater.Updater
 	systemMonitor *monitor.SystemMonitor
+	commandChan   chan socketio.Command
+	metrics       *metrics.Registry
+	healthChecker *health.Checker
+	hlsServer     *hls.Server
+	httpServer    *http.Server
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
 	startTime     time.Time
 }
 
+// onvifRetry is a background attempt to connect to an ONVIF device
+type onvifRetry struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
 func main() {
 	// Parse command line flags using pflag
 	configPath := pflag.String("config", defaultConfigPath, "Path to configuration file")
 	generateConfig := pflag.Bool("generate-config", false, "Generate sample configuration file")
 	showVersion := pflag.Bool("version", false, "Show version information")
+	discover := pflag.Bool("discover", false, "Discover ONVIF cameras on the local network and exit")
+	discoverTimeout := pflag.Duration("discover-timeout", onvif.DefaultDiscoveryTimeout, "How long to wait for discovery responses")
+	validate := pflag.Bool("validate", false, "Validate the configuration file, print the effective configuration and exit")
+	strict := pflag.Bool("strict", false, "Refuse to start with an invalid configuration instead of falling back to a backup or the defaults")
 	pflag.Parse()
 
 	// Show version if requested
@@ -55,6 +89,24 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Discover cameras if requested
+	if *discover {
+		if err := runDiscovery(*discoverTimeout); err != nil {
+			fmt.Fprintf(os.Stderr, "Discovery failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// Validate the configuration if requested
+	if *validate {
+		if err := runValidate(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Generate sample config if requested
 	if *generateConfig {
 		if *configPath != "" {
@@ -70,11 +122,15 @@ func main() {
 	}
 
 	// Create application
-	app := NewApplication(*configPath)
+	app, err := NewApplication(*configPath, *strict)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Refusing to start: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Setup signal handling
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// Start application
 	if err := app.Start(); err != nil {
@@ -82,106 +138,62 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Wait for shutdown signal
-	<-sigChan
+	// Wait for shutdown signal, reloading the configuration on SIGHUP
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			app.reloadConfig("SIGHUP")
+			continue
+		}
+		break
+	}
 	app.logger.Info("Shutdown signal received")
 
 	// Shutdown application
 	app.Shutdown()
 }
 
-// NewApplication creates a new application instance
-func NewApplication(configPath string) *Application {
+// NewApplication creates a new application instance. An invalid
+// configuration file is replaced by its newest valid backup or the defaults,
+// unless strict is set, in which case it is an error.
+func NewApplication(configPath string, strict bool) (*Application, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	app := &Application{
-		ctx:       ctx,
-		cancel:    cancel,
-		startTime: time.Now(),
+		configPath:   configPath,
+		commandChan:  make(chan socketio.Command, 32),
+		onvifRetries: make(map[string]*onvifRetry),
+		ctx:          ctx,
+		cancel:       cancel,
+		startTime:    time.Now(),
 	}
 
-	// Load configuration
-	cfg, err := config.LoadConfig(configPath)
+	cfg, backupPath, err := loadStartupConfig(configPath, strict)
 	if err != nil {
-		// Use default config if loading fails
-		cfg = &config.Config{
-			Agent: config.AgentConfig{
-				ID:             "cctv-agent-001",
-				Name:           "CCTV Agent",
-				Location:       "Main Building",
-				UpdateInterval: 30 * time.Minute,
-				LogLevel:       "info",
-				MaxConcurrency: 4,
-			},
-			Logger: config.LoggerConfig{
-				Level:         "debug",
-				ConsoleOutput: true,
-				ConsoleFormat: "text",
-				FileOutput:    true,
-				FileFormat:    "json",
-				LogDir:        "/opt/grw/cctv-agent/logs",
-				MaxSize:       100,
-				MaxBackups:    3,
-				MaxAge:        7,
-				Compress:      true,
-			},
-			SocketIO: config.SocketIOConfig{
-				Host:           "localhost",
-				Port:           9054,
-				Path:           "/socket.io",
-				ReconnectDelay: 5 * time.Second,
-				PingInterval:   30 * time.Second,
-				TLS:            false,
-			},
-			Cameras: []config.CameraConfig{},
-			FFmpeg: config.FFmpegConfig{
-				Preset:       "ultrafast",
-				Tune:         "zerolatency",
-				CRF:          23,
-				MaxRate:      "2M",
-				BufSize:      "4M",
-				AudioBitrate: "128k",
-				AudioRate:    44100,
-				VideoCodec:   "libx264",
-				AudioCodec:   "aac",
-				LogLevel:     "error",
-				ExtraArgs:    "-rtsp_transport tcp",
-			},
-			RTMP: config.RTMPConfig{
-				Host:    "localhost",
-				Port:    1935,
-				AppName: "live",
-			},
-		}
-		fmt.Fprintf(os.Stderr, "Failed to load config, using defaults: %v\n", err)
+		cancel()
+		return nil, err
 	}
 	app.config = cfg
 
 	// Initialize logger with configuration
 	loggerCfg := cfg.Logger
-	if loggerCfg.Level == "" {
-		loggerCfg.Level = cfg.Agent.LogLevel
-		if loggerCfg.Level == "" {
-			loggerCfg.Level = "info"
-		}
-	}
+	loggerCfg.Level = cfg.LogLevel()
 	if loggerCfg.LogDir == "" {
 		loggerCfg.LogDir = "logs"
 	}
 	app.logger = logger.NewLoggerWithConfig(&loggerCfg)
 	app.logger.Info("CCTV Agent starting", "version", version)
+	if backupPath != "" {
+		app.logger.Warn("Configuration file is invalid, using its latest valid backup",
+			"path", configPath,
+			"backup", backupPath)
+	}
 
 	// Initialize Socket.IO client
-	sioURL := fmt.Sprintf("ws://%s:%d", cfg.SocketIO.Host, cfg.SocketIO.Port)
-	if cfg.SocketIO.TLS {
-		sioURL = fmt.Sprintf("wss://%s:%d", cfg.SocketIO.Host, cfg.SocketIO.Port)
-	}
-	if cfg.SocketIO.Path != "" && cfg.SocketIO.Path != "/socket.io" {
-		sioURL = fmt.Sprintf("%s%s", sioURL, cfg.SocketIO.Path)
-	}
+	sioURL := socketIOURL(cfg.SocketIO)
 	app.logger.Info("Socket.IO URL configured", "url", sioURL, "path", cfg.SocketIO.Path)
 	app.sioClient = socketio.NewClient(sioURL, app.logger)
-	app.streamManager = stream.NewManager(app.config, app.logger)
+	app.results = app.sioClient
+	app.streamManager = stream.NewManager(cfg, app.logger)
 	app.onvifCtrl = onvif.NewController(app.logger)
 	app.updater = updater.NewUpdater(app.logger, version)
 	// Set the SocketIO client for update checks
@@ -211,40 +223,146 @@ func NewApplication(configPath string) *Application {
 	}
 	app.updater.ApplyConfig(uc)
 	app.systemMonitor = monitor.NewSystemMonitor(app.logger)
+	app.recorder = recording.NewManager(cfg, app.streamManager.SourceURL, app.systemMonitor, app.logger)
+	app.metrics = metrics.NewRegistry()
+	app.registerMetrics()
+	app.healthChecker = health.NewChecker(cfg.Monitoring.HealthCheckInterval, app.logger)
+	app.registerHealthChecks()
+	app.hlsServer = hls.NewServer(cfg.HLS, app.hlsCameras, app.logger)
+
+	return app, nil
+}
+
+// loadStartupConfig loads the configuration at startup. An invalid file is
+// replaced by its newest valid backup, whose path is returned, or failing
+// that by the defaults; in strict mode it is an error instead.
+func loadStartupConfig(configPath string, strict bool) (*config.Config, string, error) {
+	cfg, err := config.LoadConfig(configPath)
+	if err == nil {
+		return cfg, "", nil
+	}
+	if strict {
+		return nil, "", err
+	}
+
+	if backup, path, backupErr := config.LoadLatestBackup(configPath); backupErr == nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config, using backup %s: %v\n", path, err)
+		return backup, path, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Failed to load config, using defaults: %v\n", err)
+	return defaultConfig(), "", nil
+}
+
+// defaultConfig returns the configuration used when neither the file nor a
+// backup can be loaded
+func defaultConfig() *config.Config {
+	return &config.Config{
+		Agent: config.AgentConfig{
+			ID:             "cctv-agent-001",
+			Name:           "CCTV Agent",
+			Location:       "Main Building",
+			UpdateInterval: 30 * time.Minute,
+			LogLevel:       "info",
+			MaxConcurrency: 4,
+		},
+		Logger: config.LoggerConfig{
+			Level:         "debug",
+			ConsoleOutput: true,
+			ConsoleFormat: "text",
+			FileOutput:    true,
+			FileFormat:    "json",
+			LogDir:        "/opt/grw/cctv-agent/logs",
+			MaxSize:       100,
+			MaxBackups:    3,
+			MaxAge:        7,
+			Compress:      true,
+		},
+		SocketIO: config.SocketIOConfig{
+			Host:           "localhost",
+			Port:           9054,
+			Path:           "/socket.io",
+			ReconnectDelay: 5 * time.Second,
+			PingInterval:   30 * time.Second,
+			TLS:            false,
+		},
+		Cameras: []config.CameraConfig{},
+		FFmpeg: config.FFmpegConfig{
+			Preset:       "ultrafast",
+			Tune:         "zerolatency",
+			CRF:          23,
+			MaxRate:      "2M",
+			BufSize:      "4M",
+			AudioBitrate: "128k",
+			AudioRate:    44100,
+			VideoCodec:   "libx264",
+			AudioCodec:   "aac",
+			LogLevel:     "error",
+			ExtraArgs:    "-rtsp_transport tcp",
+		},
+		RTMP: config.RTMPConfig{
+			Host:    "localhost",
+			Port:    1935,
+			AppName: "live",
+		},
+	}
+}
+
+// socketIOURL returns the URL of the Socket.IO server
+func socketIOURL(cfg config.SocketIOConfig) string {
+	url := fmt.Sprintf("ws://%s:%d", cfg.Host, cfg.Port)
+	if cfg.TLS {
+		url = fmt.Sprintf("wss://%s:%d", cfg.Host, cfg.Port)
+	}
+	if cfg.Path != "" && cfg.Path != "/socket.io" {
+		url = fmt.Sprintf("%s%s", url, cfg.Path)
+	}
+	return url
+}
+
+// getConfig returns the current configuration. It is replaced as a whole
+// when the configuration file is reloaded, so callers should read it once
+// per operation.
+func (app *Application) getConfig() *config.Config {
+	app.configMu.RLock()
+	defer app.configMu.RUnlock()
+	return app.config
+}
 
-	return app
+// setConfig replaces the current configuration
+func (app *Application) setConfig(cfg *config.Config) {
+	app.configMu.Lock()
+	defer app.configMu.Unlock()
+	app.config = cfg
 }
 
 // Start starts the application
 func (app *Application) Start() error {
 	app.logger.Info("Starting application components")
 
-	// Updater startup finalize/health
-	if app.updater != nil {
-		app.updater.HandleStartup()
-	}
-
-	// Initialize ONVIF controller if cameras have PTZ
-	for _, camera := range app.config.Cameras {
-		if camera.PTZEnabled {
-			if err := app.onvifCtrl.Connect(
-				camera.ID,
-				camera.RTSPUrl,
-				camera.Username,
-				camera.Password,
-			); err != nil {
-				app.logger.Error("Failed to connect ONVIF device",
-					"camera_id", camera.ID,
-					"error", err)
-			}
-		}
-	}
+	// Initialize ONVIF controller for PTZ cameras and stream URL resolution
+	unreachable := app.connectONVIFDevices()
 
 	// Start stream manager
 	if err := app.streamManager.Start(); err != nil {
 		return fmt.Errorf("failed to start stream manager: %w", err)
 	}
 
+	// Start local recording
+	if err := app.recorder.Start(); err != nil {
+		app.logger.Error("Failed to start recording", "error", err)
+	}
+
+	// Cameras of unreachable devices are started once they can be reached
+	for _, camera := range unreachable {
+		app.retryONVIFDevice(camera)
+	}
+
+	// Serve metrics and health endpoints
+	if err := app.startHTTPServer(); err != nil {
+		app.logger.Error("Failed to start HTTP server", "error", err)
+	}
+
 	app.sioClient.RegisterEventHandler("pong", func(data json.RawMessage) error {
 		app.logger.Info("Socket.IO pong", "pong", data)
 
@@ -278,20 +396,36 @@ func (app *Application) Start() error {
 	})
 
 	// Start background tasks
-	bgCount := 2
-	if app.updater != nil && app.config.Updater.Enabled {
+	updaterEnabled := app.updater != nil && app.getConfig().Updater.Enabled
+	bgCount := 5
+	if updaterEnabled {
 		bgCount++
 	}
 	app.wg.Add(bgCount)
 	go app.processCommands()
 	go app.reportStatus()
-	if app.updater != nil && app.config.Updater.Enabled {
+	go app.forwardStatusUpdates()
+	go app.watchConfig()
+	go func() {
+		defer app.wg.Done()
+		app.healthChecker.Run(app.ctx)
+	}()
+	if updaterEnabled {
 		go func() {
 			defer app.wg.Done()
 			app.updater.RunPeriodic(app.ctx)
 		}()
 	}
 
+	// Roll back an upgrade that does not become ready
+	if app.updater != nil {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			app.updater.HandleStartup(app.ctx, app.healthChecker)
+		}()
+	}
+
 	app.logger.Info("Application started successfully")
 	return nil
 }
@@ -303,11 +437,19 @@ func (app *Application) Shutdown() {
 	// Cancel context to stop all components
 	app.cancel()
 
+	// Stop serving HTTP endpoints
+	app.stopHTTPServer()
+
 	// Stop stream manager
 	if app.streamManager != nil {
 		app.streamManager.Stop()
 	}
 
+	// Stop recording
+	if app.recorder != nil {
+		app.recorder.Stop()
+	}
+
 	// Disconnect Socket.IO
 	if app.sioClient != nil {
 		app.sioClient.Disconnect()
@@ -327,55 +469,229 @@ func (app *Application) processCommands() {
 		select {
 		case <-app.ctx.Done():
 			return
+		case cmd := <-app.commandChan:
+			// Slow commands run on their own so that they do not hold up
+			// PTZ and stream commands queued behind them
+			if asyncCommands[cmd.Type] {
+				app.wg.Add(1)
+				go func() {
+					defer app.wg.Done()
+					app.runCommand(cmd)
+				}()
+				continue
+			}
+			app.runCommand(cmd)
 		}
 	}
 }
 
-// handleCommand handles a single command
-func (app *Application) handleCommand(cmd json.RawMessage) error {
-	app.logger.Info("Processing command")
+// connectONVIFDevices connects to the ONVIF service of cameras with PTZ or an
+// ONVIF host, and hands stream URLs resolved from their media profiles to the
+// stream manager. Devices are connected concurrently, so that unreachable
+// ones do not delay the others, and the cameras whose devices could not be
+// connected are returned.
+func (app *Application) connectONVIFDevices() []*config.CameraConfig {
+	cfg := app.getConfig()
+
+	var mu sync.Mutex
+	var unreachable []*config.CameraConfig
+	var eg errgroup.Group
+	if cfg.Agent.MaxConcurrency > 0 {
+		eg.SetLimit(cfg.Agent.MaxConcurrency)
+	}
+	for i := range cfg.Cameras {
+		camera := &cfg.Cameras[i]
+		eg.Go(func() error {
+			if err := app.connectONVIFDevice(camera); err != nil {
+				app.logger.Error("Failed to connect ONVIF device",
+					"camera_id", camera.ID,
+					"error", err)
+				mu.Lock()
+				unreachable = append(unreachable, camera)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	eg.Wait()
+
+	return unreachable
+}
+
+// connectONVIFDevice connects to the ONVIF service of a camera with PTZ or an
+// ONVIF host and resolves its stream and snapshot URIs. An error is returned
+// when the device cannot be reached or its stream URIs cannot be resolved, in
+// which case connecting again later may succeed.
+func (app *Application) connectONVIFDevice(camera *config.CameraConfig) error {
+	if !camera.PTZEnabled && camera.ONVIFHost == "" {
+		return nil
+	}
+
+	endpoint, err := camera.ONVIFEndpoint()
+	if err != nil {
+		// Retrying cannot fix the configuration
+		app.logger.Error("Invalid ONVIF endpoint",
+			"camera_id", camera.ID,
+			"error", err)
+		return nil
+	}
+
+	// A device that was connected before its stream URIs could be resolved
+	// stays connected for PTZ
+	if !app.onvifCtrl.IsConnected(camera.ID) {
+		if err := app.onvifCtrl.Connect(
+			camera.ID,
+			endpoint,
+			camera.Username,
+			camera.Password,
+		); err != nil {
+			return err
+		}
+	}
+
+	var urls stream.SourceURLs
+	if camera.ONVIFHost != "" {
+		streams, err := app.onvifCtrl.ResolveStreams(camera.ID, camera.ONVIFProfile)
+		if err != nil {
+			return fmt.Errorf("failed to resolve ONVIF stream URIs: %w", err)
+		}
+		urls.Main = streams.Main.URI
+		if streams.Sub != nil {
+			urls.Sub = streams.Sub.URI
+		}
+	}
 
-	// TODO: Implement command handling
+	// Snapshots fall back to an FFmpeg frame grab without a snapshot URI
+	if uri, err := app.onvifCtrl.GetSnapshotURI(camera.ID); err != nil {
+		app.logger.Warn("Failed to get ONVIF snapshot URI",
+			"camera_id", camera.ID,
+			"error", err)
+	} else {
+		urls.Snapshot = uri
+	}
+	app.streamManager.SetSourceURLs(camera.ID, urls)
 	return nil
 }
 
-// restartComponents restarts components with new configuration
-func (app *Application) restartComponents() {
-	app.logger.Info("Restarting components with new configuration")
+// retryONVIFDevice keeps connecting to the ONVIF service of a camera in the
+// background, waiting between attempts as the camera's retry policy
+// prescribes, and starts the camera's stream and recording once connected. A previous
+// attempt for the camera is stopped first.
+func (app *Application) retryONVIFDevice(camera *config.CameraConfig) {
+	app.stopONVIFRetry(camera.ID)
+
+	ctx, cancel := context.WithCancel(app.ctx)
+	retry := &onvifRetry{cancel: cancel, done: make(chan struct{})}
+	app.onvifMu.Lock()
+	app.onvifRetries[camera.ID] = retry
+	app.onvifMu.Unlock()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer close(retry.done)
+		defer func() {
+			app.onvifMu.Lock()
+			if app.onvifRetries[camera.ID] == retry {
+				delete(app.onvifRetries, camera.ID)
+			}
+			app.onvifMu.Unlock()
+		}()
 
-	// Restart stream manager
-	app.streamManager.Stop()
-	app.streamManager = stream.NewManager(app.config, app.logger)
-	app.streamManager.Start()
+		log := app.logger.With("camera_id", camera.ID)
+		policy := stream.NewRetryPolicy(camera)
+		for failures := 1; ; failures++ {
+			delay, _ := policy.Next(failures)
+			log.Info("Retrying ONVIF connection", "delay", delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
 
-	// Set up Socket.IO handlers
-	app.sioClient.OnConnect(func() {
-		app.logger.Info("Socket.IO connected")
-		app.sendRegistration()
-	})
+			err := app.connectONVIFDevice(camera)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Warn("ONVIF device still unavailable",
+					"failures", failures,
+					"error", err)
+				continue
+			}
 
-	app.sioClient.OnDisconnect(func() {
-		app.logger.Warn("Socket.IO disconnected")
-	})
+			log.Info("ONVIF device connected", "failures", failures)
+			app.startCamera(camera.ID)
+			return
+		}
+	}()
+}
 
-	app.sioClient.RegisterEventHandler("command", func(data json.RawMessage) error {
-		return app.handleCommand(data)
-	})
+// stopONVIFRetry stops connecting to the ONVIF service of a camera in the
+// background and waits for the current attempt to finish
+func (app *Application) stopONVIFRetry(cameraID string) {
+	app.onvifMu.Lock()
+	retry, exists := app.onvifRetries[cameraID]
+	delete(app.onvifRetries, cameraID)
+	app.onvifMu.Unlock()
+
+	if exists {
+		retry.cancel()
+		<-retry.done
+	}
+}
 
-	// Re-initialize ONVIF devices
-	app.onvifCtrl = onvif.NewController(app.logger)
-	for _, camera := range app.config.Cameras {
-		if camera.PTZEnabled {
-			app.onvifCtrl.Connect(
-				camera.ID,
-				camera.RTSPUrl,
-				camera.Username,
-				camera.Password,
-			)
+// retryingONVIF reports whether a camera's ONVIF device is being connected
+// in the background
+func (app *Application) retryingONVIF(cameraID string) bool {
+	app.onvifMu.Lock()
+	defer app.onvifMu.Unlock()
+	_, exists := app.onvifRetries[cameraID]
+	return exists
+}
+
+// startCamera starts the stream and recording of an enabled camera whose
+// source URL was resolved late, unless they are already running
+func (app *Application) startCamera(cameraID string) {
+	camera, err := app.getConfig().GetCameraByID(cameraID)
+	if err != nil || !camera.Enabled {
+		return
+	}
+
+	if _, err := app.streamManager.GetStreamStatus(cameraID); err != nil {
+		if err := app.streamManager.AddCamera(camera); err != nil {
+			app.logger.Error("Failed to start stream",
+				"camera_id", cameraID,
+				"error", err)
+		}
+	}
+
+	if !app.recorder.IsRecording(cameraID) {
+		if err := app.recorder.AddCamera(camera); err != nil {
+			app.logger.Error("Failed to start recording",
+				"camera_id", cameraID,
+				"error", err)
 		}
 	}
 }
 
+// cameraProfile returns the ONVIF media profile a camera streams from
+func (app *Application) cameraProfile(cameraID string) *socketio.MediaProfile {
+	profile, exists := app.onvifCtrl.GetStreamProfile(cameraID)
+	if !exists {
+		return nil
+	}
+	return &socketio.MediaProfile{
+		Token:    profile.Token,
+		Name:     profile.Name,
+		Encoding: profile.Encoding,
+		Width:    profile.Width,
+		Height:   profile.Height,
+		FPS:      profile.FPS,
+		Bitrate:  profile.Bitrate,
+	}
+}
+
 // reportStatus periodically reports status to server
 func (app *Application) reportStatus() {
 	defer app.wg.Done()
@@ -393,11 +709,79 @@ func (app *Application) reportStatus() {
 	}
 }
 
+// forwardStatusUpdates pushes stream status transitions to the server as
+// they happen. Transitions arriving within statusCoalesceWindow are merged so
+// that a burst results in a single event per camera.
+func (app *Application) forwardStatusUpdates() {
+	defer app.wg.Done()
+
+	updates := app.streamManager.GetStatusChannel()
+	pending := make(map[string]stream.StatusUpdate)
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				// Stream manager stopped, nothing more to forward
+				updates = nil
+				continue
+			}
+			pending[update.CameraID] = update
+			if flush == nil {
+				flush = time.After(statusCoalesceWindow)
+			}
+		case <-flush:
+			flush = nil
+			for id, update := range pending {
+				app.sendCameraStatus(update)
+				delete(pending, id)
+			}
+		}
+	}
+}
+
+// sendCameraStatus sends a single camera status transition
+func (app *Application) sendCameraStatus(update stream.StatusUpdate) {
+	status := socketio.CameraStatus{
+		ID:             update.CameraID,
+		Status:         string(update.Status),
+		Connected:      update.Status == stream.StatusConnected,
+		Streaming:      update.Status == stream.StatusConnected,
+		Recording:      app.recorder.GetRecorderInfo()[update.CameraID].Recording,
+		LastTransition: update.Timestamp,
+		LastUpdate:     time.Now(),
+		Error:          update.Error,
+		Profile:        app.cameraProfile(update.CameraID),
+	}
+	if info, exists := app.streamManager.GetStreamInfo()[update.CameraID]; exists {
+		status.Uptime = info.Uptime
+		status.RetryCount = info.RetryCount
+		if !info.NextRetry.IsZero() {
+			nextRetry := info.NextRetry
+			status.NextRetry = &nextRetry
+		}
+		status.CircuitOpen = info.CircuitOpen
+		if !info.Stats.UpdatedAt.IsZero() {
+			status.Stats = streamStats(info.Stats)
+		}
+		status.Encoding = string(info.Encoding)
+		status.Source = sourceInfo(info.Probe)
+		status.Outputs = outputStatuses(info.Outputs)
+	}
+
+	if err := app.sioClient.Emit("camera_status", status); err != nil {
+		app.logger.Error("Failed to send camera status", "camera_id", update.CameraID, "error", err)
+	}
+}
+
 // sendRegistration sends registration message
 func (app *Application) sendRegistration() {
 	hostname, _ := os.Hostname()
 	reg := socketio.Registration{
-		AgentID:  app.config.Agent.ID,
+		AgentID:  app.getConfig().Agent.ID,
 		Name:     hostname,
 		Location: "Raspberry Pi",
 		Version:  version,
@@ -411,28 +795,19 @@ func (app *Application) sendRegistration() {
 // sendStatusReport sends status report
 func (app *Application) sendStatusReport() {
 	// Get camera statuses
-	cameraStatuses := make(map[string]socketio.CameraStatus)
-	// TODO: Get actual camera statuses from stream manager
-	for _, camera := range app.config.Cameras {
-		cameraStatuses[camera.ID] = socketio.CameraStatus{
-			ID:         camera.ID,
-			Connected:  false,
-			Streaming:  false,
-			LastUpdate: time.Now(),
-			Error:      "",
-		}
-	}
+	cameraStatuses := app.getCameraStatuses()
 
 	// Get system info
 	systemInfo := app.getSystemInfo()
 
 	// Create status report
 	report := socketio.StatusReport{
-		AgentID:      app.config.Agent.ID,
+		AgentID:      app.getConfig().Agent.ID,
 		Version:      version,
 		Uptime:       time.Since(app.startTime),
 		CameraStatus: cameraStatuses,
 		SystemInfo:   systemInfo,
+		Recording:    app.getRecordingStatus(),
 		Timestamp:    time.Now(),
 	}
 
@@ -441,6 +816,138 @@ func (app *Application) sendStatusReport() {
 	}
 }
 
+// getRecordingStatus reports the recording storage and the segments evicted
+// since the last report, or nil when nothing has been recorded
+func (app *Application) getRecordingStatus() *socketio.RecordingStatus {
+	storage := app.recorder.GetStorageInfo()
+	if storage.CheckedAt.IsZero() {
+		return nil
+	}
+
+	status := &socketio.RecordingStatus{
+		Segments:  storage.Segments,
+		UsedBytes: storage.UsedBytes,
+		FreeBytes: storage.FreeBytes,
+	}
+	for _, eviction := range app.recorder.TakeEvictions() {
+		status.Evictions = append(status.Evictions, socketio.SegmentEviction{
+			CameraID: eviction.CameraID,
+			File:     eviction.File,
+			Size:     eviction.Size,
+			Reason:   eviction.Reason,
+			Time:     eviction.Time,
+		})
+	}
+
+	return status
+}
+
+// getCameraStatuses builds the status of every configured or running camera
+func (app *Application) getCameraStatuses() map[string]socketio.CameraStatus {
+	now := time.Now()
+	streams := app.streamManager.GetStreamInfo()
+	recorders := app.recorder.GetRecorderInfo()
+	cameraStatuses := make(map[string]socketio.CameraStatus, len(streams))
+
+	for id, info := range streams {
+		status := cameraStatusFromInfo(info, now)
+		status.Recording = recorders[id].Recording
+		status.Profile = app.cameraProfile(id)
+		cameraStatuses[id] = status
+	}
+
+	// Cameras without a stream are either disabled or were stopped
+	for _, camera := range app.getConfig().Cameras {
+		if _, exists := cameraStatuses[camera.ID]; exists {
+			continue
+		}
+		status := string(stream.StatusDisconnected)
+		if !camera.Enabled {
+			status = "disabled"
+		}
+		cameraStatuses[camera.ID] = socketio.CameraStatus{
+			ID:         camera.ID,
+			Status:     status,
+			Recording:  recorders[camera.ID].Recording,
+			LastUpdate: now,
+		}
+	}
+
+	return cameraStatuses
+}
+
+// cameraStatusFromInfo converts a stream snapshot into a camera status
+func cameraStatusFromInfo(info stream.StreamInfo, now time.Time) socketio.CameraStatus {
+	status := socketio.CameraStatus{
+		ID:             info.CameraID,
+		Status:         string(info.Status),
+		Connected:      info.Status == stream.StatusConnected,
+		Streaming:      info.Status == stream.StatusConnected,
+		Uptime:         info.Uptime,
+		RetryCount:     info.RetryCount,
+		LastTransition: info.LastTransition,
+		LastUpdate:     now,
+		Error:          info.LastError,
+	}
+	if !info.NextRetry.IsZero() {
+		nextRetry := info.NextRetry
+		status.NextRetry = &nextRetry
+	}
+	status.CircuitOpen = info.CircuitOpen
+	if !info.Stats.UpdatedAt.IsZero() {
+		status.Stats = streamStats(info.Stats)
+	}
+	status.Encoding = string(info.Encoding)
+	status.Source = sourceInfo(info.Probe)
+	status.Outputs = outputStatuses(info.Outputs)
+	return status
+}
+
+// streamStats converts FFmpeg statistics into their wire representation
+func streamStats(stats stream.Stats) *socketio.StreamStats {
+	return &socketio.StreamStats{
+		FPS:              stats.FPS,
+		Bitrate:          stats.Bitrate,
+		Frames:           stats.Frames,
+		DroppedFrames:    stats.DropFrames,
+		DuplicatedFrames: stats.DupFrames,
+		Speed:            stats.Speed,
+		OutTime:          stats.OutTime,
+		UpdatedAt:        stats.UpdatedAt,
+	}
+}
+
+// outputStatuses converts stream output states into their wire representation
+func outputStatuses(outputs []stream.OutputStatus) []socketio.OutputStatus {
+	statuses := make([]socketio.OutputStatus, len(outputs))
+	for i, output := range outputs {
+		statuses[i] = socketio.OutputStatus{
+			Name:   output.Name,
+			Type:   output.Type,
+			Status: string(output.State),
+			Error:  output.Error,
+			Since:  output.Since,
+		}
+	}
+	return statuses
+}
+
+// sourceInfo converts a probe result into its wire representation
+func sourceInfo(probe *stream.ProbeResult) *socketio.SourceInfo {
+	if probe == nil {
+		return nil
+	}
+	return &socketio.SourceInfo{
+		VideoCodec: probe.VideoCodec,
+		Width:      probe.Width,
+		Height:     probe.Height,
+		FPS:        probe.FPS,
+		AudioCodec: probe.AudioCodec,
+		HasAudio:   probe.HasAudio,
+		ProbedAt:   probe.ProbedAt,
+	}
+}
+
 // getSystemInfo gets system information
 func (app *Application) getSystemInfo() socketio.SystemInfo {
 	stats, err := app.systemMonitor.GetSystemStats()
@@ -627,6 +1134,53 @@ func generateSampleConfigToFile(path string) error {
 	return nil
 }
 
+// runDiscovery prints the ONVIF devices found on the local network as JSON
+func runDiscovery(timeout time.Duration) error {
+	devices, err := onvif.Discover(context.Background(), timeout)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal devices: %w", err)
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+// runValidate checks a configuration file and prints the effective
+// configuration, with defaults applied and secrets redacted
+func runValidate(path string) error {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		return err
+	}
+	validationErr := cfg.Validate()
+
+	data, err := json.MarshalIndent(cfg.Redacted(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	fmt.Println(string(data))
+
+	if validationErr == nil {
+		fmt.Fprintf(os.Stderr, "Configuration %s is valid\n", path)
+		return nil
+	}
+
+	problems := []error{validationErr}
+	if joined, ok := validationErr.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	}
+	msg := fmt.Sprintf("Configuration %s has %d problem(s):", path, len(problems))
+	for _, problem := range problems {
+		msg += "\n  - " + problem.Error()
+	}
+	return errors.New(msg)
+}
+
 func getHostname() string {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadStartupConfig(t *testing.T) {
+	valid := func(agentID string) string {
+		return fmt.Sprintf(`{"agent": {"id": %q}, "cameras": [{"id": "gate", "rtsp_url": "rtsp://10.0.0.2/stream"}]}`, agentID)
+	}
+
+	tests := []struct {
+		name       string
+		primary    string // Empty for a missing file
+		backup     string // Empty for no backup
+		strict     bool
+		wantErr    bool
+		wantAgent  string
+		wantBackup bool
+	}{
+		{name: "valid file", primary: valid("primary"), strict: true, wantAgent: "primary"},
+		{name: "invalid file, backup", primary: `{"agent": `, backup: valid("backup"), wantAgent: "backup", wantBackup: true},
+		{name: "invalid file, no backup", primary: `{"agent": `, wantAgent: defaultConfig().Agent.ID},
+		{name: "missing file, backup", backup: valid("backup"), wantAgent: "backup", wantBackup: true},
+		{name: "strict, invalid file, backup", primary: `{"agent": `, backup: valid("backup"), strict: true, wantErr: true},
+		{name: "strict, missing file", strict: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if tt.primary != "" {
+				if err := os.WriteFile(path, []byte(tt.primary), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.backup != "" {
+				if err := os.WriteFile(path+".20240101T120000.000Z.bak", []byte(tt.backup), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, backup, err := loadStartupConfig(path, tt.strict)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("loaded agent %q, want an error", cfg.Agent.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Agent.ID != tt.wantAgent {
+				t.Errorf("agent = %q, want %q", cfg.Agent.ID, tt.wantAgent)
+			}
+			if (backup != "") != tt.wantBackup {
+				t.Errorf("backup = %q, want one used: %v", backup, tt.wantBackup)
+			}
+		})
+	}
+}
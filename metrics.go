@@ -0,0 +1,175 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cctv-agent/internal/metrics"
+	"github.com/cctv-agent/internal/stream"
+	"github.com/cctv-agent/internal/updater"
+)
+
+// streamStatuses lists the states exported for every stream
+var streamStatuses = []stream.StreamStatus{
+	stream.StatusDisconnected,
+	stream.StatusConnecting,
+	stream.StatusConnected,
+	stream.StatusError,
+	stream.StatusReconnecting,
+	stream.StatusStalled,
+}
+
+// updaterStates lists the states exported for the updater
+var updaterStates = []updater.State{
+	updater.StateIdle,
+	updater.StateChecking,
+	updater.StateDownloading,
+	updater.StateInstalling,
+	updater.StateRestarting,
+}
+
+// registerMetrics registers the collectors exposed on /metrics
+func (app *Application) registerMetrics() {
+	app.metrics.Register(app.collectAgentMetrics)
+	app.metrics.Register(app.collectStreamMetrics)
+	app.metrics.Register(app.collectRecordingMetrics)
+	app.metrics.Register(app.collectUpdaterMetrics)
+	app.metrics.Register(app.collectSystemMetrics)
+}
+
+// collectAgentMetrics collects agent and Socket.IO metrics
+func (app *Application) collectAgentMetrics(s *metrics.Set) {
+	s.Gauge("cctv_agent_info", "Agent version and identity.", 1,
+		"agent_id", app.getConfig().Agent.ID,
+		"version", version)
+	s.Gauge("cctv_agent_uptime_seconds", "Time since the agent started.",
+		time.Since(app.startTime).Seconds())
+	s.Gauge("cctv_socketio_connected", "Whether the Socket.IO connection is up.",
+		metrics.BoolValue(app.sioClient.IsConnected()))
+}
+
+// collectStreamMetrics collects per-camera stream metrics
+func (app *Application) collectStreamMetrics(s *metrics.Set) {
+	streams := app.streamManager.GetStreamInfo()
+
+	ids := make([]string, 0, len(streams))
+	for id := range streams {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		info := streams[id]
+		for _, status := range streamStatuses {
+			s.Gauge("cctv_stream_status", "Current stream state, 1 for the active state.",
+				metrics.BoolValue(info.Status == status),
+				"camera_id", id,
+				"status", string(status))
+		}
+		s.Gauge("cctv_stream_up", "Whether the stream is connected.",
+			metrics.BoolValue(info.Status == stream.StatusConnected),
+			"camera_id", id)
+		s.Gauge("cctv_stream_uptime_seconds", "Time since FFmpeg started for a connected stream.",
+			info.Uptime.Seconds(),
+			"camera_id", id)
+		s.Counter("cctv_stream_restarts_total", "Number of times FFmpeg was restarted.",
+			float64(info.Restarts),
+			"camera_id", id)
+		s.Gauge("cctv_stream_retries", "Consecutive failed stream attempts.",
+			float64(info.RetryCount),
+			"camera_id", id)
+		s.Gauge("cctv_stream_circuit_open", "Whether retries are paused after repeated failures.",
+			metrics.BoolValue(info.CircuitOpen),
+			"camera_id", id)
+		for _, output := range info.Outputs {
+			s.Gauge("cctv_stream_output_up", "Whether a stream output is being written.",
+				metrics.BoolValue(output.State == stream.OutputActive),
+				"camera_id", id, "output", output.Name, "type", output.Type)
+		}
+
+		if info.Stats.UpdatedAt.IsZero() {
+			continue
+		}
+		s.Gauge("cctv_stream_fps", "Frames per second encoded by FFmpeg.",
+			info.Stats.FPS,
+			"camera_id", id)
+		s.Gauge("cctv_stream_bitrate_kbps", "Output bitrate reported by FFmpeg.",
+			info.Stats.Bitrate,
+			"camera_id", id)
+		s.Gauge("cctv_stream_speed", "Encoding speed relative to real time.",
+			info.Stats.Speed,
+			"camera_id", id)
+		s.Counter("cctv_stream_frames_total", "Frames encoded by the current FFmpeg process.",
+			float64(info.Stats.Frames),
+			"camera_id", id)
+		s.Counter("cctv_stream_dropped_frames_total", "Frames dropped by the current FFmpeg process.",
+			float64(info.Stats.DropFrames),
+			"camera_id", id)
+		s.Counter("cctv_stream_duplicated_frames_total", "Frames duplicated by the current FFmpeg process.",
+			float64(info.Stats.DupFrames),
+			"camera_id", id)
+		s.Gauge("cctv_stream_progress_timestamp_seconds", "Time of the last FFmpeg progress report.",
+			float64(info.Stats.UpdatedAt.Unix()),
+			"camera_id", id)
+	}
+}
+
+// collectRecordingMetrics collects local recording metrics
+func (app *Application) collectRecordingMetrics(s *metrics.Set) {
+	recorders := app.recorder.GetRecorderInfo()
+
+	ids := make([]string, 0, len(recorders))
+	for id := range recorders {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		s.Gauge("cctv_recording_active", "Whether the camera is being recorded.",
+			metrics.BoolValue(recorders[id].Recording),
+			"camera_id", id)
+	}
+
+	storage := app.recorder.GetStorageInfo()
+	if storage.CheckedAt.IsZero() {
+		return
+	}
+	s.Gauge("cctv_recording_segments", "Number of recorded segments on disk.", float64(storage.Segments))
+	s.Gauge("cctv_recording_used_bytes", "Size of all recorded segments.", float64(storage.UsedBytes))
+	s.Gauge("cctv_recording_free_bytes", "Free space on the recording filesystem.", float64(storage.FreeBytes))
+}
+
+// collectUpdaterMetrics collects updater metrics
+func (app *Application) collectUpdaterMetrics(s *metrics.Set) {
+	status := app.updater.GetStatus()
+
+	for _, state := range updaterStates {
+		s.Gauge("cctv_updater_state", "Current updater state, 1 for the active state.",
+			metrics.BoolValue(status.State == state),
+			"state", string(state))
+	}
+	s.Counter("cctv_updater_checks_total", "Number of update checks.", float64(status.Checks))
+	s.Counter("cctv_updater_failures_total", "Number of failed update checks.", float64(status.Failures))
+	if !status.LastCheck.IsZero() {
+		s.Gauge("cctv_updater_last_check_timestamp_seconds", "Time of the last update check.",
+			float64(status.LastCheck.Unix()))
+	}
+}
+
+// collectSystemMetrics collects system resource metrics
+func (app *Application) collectSystemMetrics(s *metrics.Set) {
+	stats, err := app.systemMonitor.GetSystemStats()
+	if err != nil {
+		app.logger.Error("Failed to get system stats", "error", err)
+		return
+	}
+
+	s.Gauge("cctv_system_cpu_usage_percent", "CPU usage.", stats.CPUUsage)
+	s.Gauge("cctv_system_memory_usage_percent", "Memory usage.", stats.MemoryUsage)
+	s.Gauge("cctv_system_disk_usage_percent", "Root filesystem usage.", stats.DiskUsage)
+	s.Gauge("cctv_system_temperature_celsius", "CPU temperature.", stats.Temperature)
+	s.Counter("cctv_system_network_sent_bytes_total", "Bytes sent on all interfaces.",
+		float64(stats.Network.BytesSent))
+	s.Counter("cctv_system_network_received_bytes_total", "Bytes received on all interfaces.",
+		float64(stats.Network.BytesReceived))
+}
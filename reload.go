@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/recording"
+	"github.com/cctv-agent/internal/stream"
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay is how long the configuration file must stay unchanged
+// before it is reloaded, as editors often write it in several steps
+const configReloadDelay = 500 * time.Millisecond
+
+// watchConfig reloads the configuration whenever its file changes. The
+// directory is watched rather than the file so that editors replacing the
+// file by renaming are noticed.
+func (app *Application) watchConfig() {
+	defer app.wg.Done()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		app.logger.Error("Failed to watch configuration file", "error", err)
+		return
+	}
+	defer watcher.Close()
+
+	path := filepath.Clean(app.configPath)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		app.logger.Error("Failed to watch configuration file",
+			"path", path,
+			"error", err)
+		return
+	}
+	app.logger.Info("Watching configuration file", "path", path)
+
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || !event.Has(fsnotify.Write|fsnotify.Create) {
+				continue
+			}
+			reload = time.After(configReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			app.logger.Warn("Configuration watcher error", "error", err)
+		case <-reload:
+			reload = nil
+			app.reloadConfig("file changed")
+		}
+	}
+}
+
+// reloadConfig loads the configuration file and applies what changed. An
+// invalid file is logged and the current configuration kept.
+func (app *Application) reloadConfig(reason string) {
+	app.reloadMu.Lock()
+	defer app.reloadMu.Unlock()
+
+	cfg, err := config.LoadConfig(app.configPath)
+	if err != nil {
+		app.logger.Error("Failed to reload configuration, keeping the current one",
+			"reason", reason,
+			"error", err)
+		return
+	}
+
+	app.applyConfig(cfg, reason)
+}
+
+// applyConfig switches to a new configuration, touching only the components
+// affected by the change: cameras are added, removed or restarted one by one
+// so that the other feeds keep streaming. Callers must hold reloadMu.
+func (app *Application) applyConfig(cfg *config.Config, reason string) *config.Diff {
+	old := app.getConfig()
+	diff := old.Compare(cfg)
+
+	// The HLS server only starts with the agent, so the running settings,
+	// and the lan outputs they add to the cameras, are kept until a restart
+	if !reflect.DeepEqual(old.HLS, cfg.HLS) {
+		cfg.HLS = old.HLS
+		diff.Cameras = old.Compare(cfg).Cameras
+	}
+	if diff.Empty() {
+		app.logger.Info("Configuration unchanged", "reason", reason)
+		return diff
+	}
+
+	app.logger.Info("Applying configuration changes",
+		"reason", reason,
+		"changes", diff.Changes())
+
+	app.setConfig(cfg)
+	app.streamManager.SetConfig(cfg)
+	app.recorder.SetConfig(cfg)
+
+	if diff.LogLevel {
+		app.logger.SetLevel(cfg.LogLevel())
+		app.logger.Info("Log level changed", "level", cfg.LogLevel())
+	}
+
+	for _, change := range diff.Cameras {
+		app.applyCameraChange(cfg, change)
+	}
+
+	if diff.Recording {
+		app.restartRecorders(cfg)
+	}
+
+	if diff.SocketIO {
+		sioURL := socketIOURL(cfg.SocketIO)
+		app.logger.Info("Reconnecting to Socket.IO server", "url", sioURL)
+		if err := app.sioClient.Reconnect(sioURL); err != nil {
+			app.logger.Error("Failed to reconnect to Socket.IO server", "error", err)
+		}
+	}
+
+	if len(diff.RestartRequired) > 0 {
+		app.logger.Warn("Some configuration changes take effect after a restart",
+			"sections", diff.RestartRequired)
+	}
+
+	return diff
+}
+
+// applyCameraChange starts, stops or restarts the stream, recording and
+// ONVIF connection of a single camera. Disabled cameras keep their ONVIF
+// connection for PTZ but do not stream.
+func (app *Application) applyCameraChange(cfg *config.Config, change config.CameraChange) {
+	log := app.logger.With("camera_id", change.ID)
+
+	if change.Action == config.CameraRemoved {
+		// Cameras only used for PTZ have no stream to remove
+		app.streamManager.RemoveCamera(change.ID)
+		app.recorder.RemoveCamera(change.ID)
+		app.disconnectONVIFDevice(change.ID)
+		log.Info("Camera removed")
+		return
+	}
+
+	camera, err := cfg.GetCameraByID(change.ID)
+	if err != nil {
+		log.Error("Failed to apply camera configuration", "error", err)
+		return
+	}
+
+	// The recording is started again once the source URL is resolved
+	app.recorder.RemoveCamera(change.ID)
+
+	// Credentials or the ONVIF endpoint may have changed
+	if change.Settings {
+		app.disconnectONVIFDevice(change.ID)
+		if err := app.connectONVIFDevice(camera); err != nil {
+			log.Error("Failed to connect ONVIF device", "error", err)
+			app.retryONVIFDevice(camera)
+		}
+	}
+
+	if !camera.Enabled {
+		if err := app.streamManager.RemoveCamera(change.ID); err == nil {
+			log.Info("Camera disabled")
+		}
+		return
+	}
+
+	// The stream and recording are started once the device can be reached
+	if app.streamManager.SourceURL(camera) == "" && app.retryingONVIF(change.ID) {
+		if change.Action == config.CameraUpdated {
+			app.streamManager.RemoveCamera(change.ID)
+		}
+		log.Info("Camera waits for its ONVIF device", "action", change.Action)
+		return
+	}
+
+	if change.Action == config.CameraAdded {
+		err = app.streamManager.AddCamera(camera)
+	} else {
+		err = app.streamManager.UpdateCameraConfig(camera)
+	}
+	if err != nil {
+		log.Error("Failed to start stream", "error", err)
+	}
+
+	if err := app.recorder.AddCamera(camera); err != nil {
+		log.Error("Failed to start recording", "error", err)
+	}
+
+	log.Info("Camera configuration applied", "action", change.Action)
+}
+
+// restartRecorders restarts every recording with the current recording
+// settings
+func (app *Application) restartRecorders(cfg *config.Config) {
+	for _, id := range app.recorder.Recording() {
+		app.recorder.RemoveCamera(id)
+	}
+
+	// Cameras without a source URL yet are recorded once it is resolved
+	cameras := cfg.GetEnabledCameras()
+	for i := range cameras {
+		if err := app.recorder.AddCamera(&cameras[i]); err != nil && !errors.Is(err, recording.ErrNoURL) {
+			app.logger.Error("Failed to start recording",
+				"camera_id", cameras[i].ID,
+				"error", err)
+		}
+	}
+}
+
+// disconnectONVIFDevice forgets the ONVIF connection and resolved URLs of a
+// camera, and stops connecting to it in the background
+func (app *Application) disconnectONVIFDevice(cameraID string) {
+	app.stopONVIFRetry(cameraID)
+	if app.onvifCtrl.IsConnected(cameraID) {
+		if err := app.onvifCtrl.Disconnect(cameraID); err != nil {
+			app.logger.Warn("Failed to disconnect ONVIF device",
+				"camera_id", cameraID,
+				"error", err)
+		}
+	}
+	app.streamManager.SetSourceURLs(cameraID, stream.SourceURLs{})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/cctv-agent/config"
+	"github.com/cctv-agent/internal/recording"
+	"github.com/cctv-agent/internal/stream"
+)
+
+func TestApplyConfigKeepsHLSUntilRestart(t *testing.T) {
+	load := func(data string) *config.Config {
+		t.Helper()
+		cfg, err := config.LoadConfigFromJSON([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	cfg := load(`{"agent": {"id": "agent-1"}, "socketio": {"host": "localhost"}, "cameras": [{"id": "cam1", "rtsp_url": "rtsp://10.0.0.2/stream"}]}`)
+	next := load(`{"agent": {"id": "agent-1"}, "socketio": {"host": "localhost"}, "hls": {"enabled": true}, "cameras": [{"id": "cam1", "rtsp_url": "rtsp://10.0.0.2/stream"}]}`)
+
+	app, _ := newTestApplication(t, 1)
+	app.config = cfg
+	app.streamManager = stream.NewManager(cfg, app.logger)
+	app.recorder = recording.NewManager(cfg, app.streamManager.SourceURL, nil, app.logger)
+
+	diff := app.applyConfig(next, "test")
+
+	// The server is not running, so no camera gains a lan output yet
+	if len(diff.RestartRequired) != 1 || diff.RestartRequired[0] != "hls" {
+		t.Errorf("restart required for %v, want hls", diff.RestartRequired)
+	}
+	if len(diff.Cameras) != 0 {
+		t.Errorf("camera changes = %+v, want none", diff.Cameras)
+	}
+	running := app.getConfig()
+	if running.HLS.Enabled {
+		t.Error("HLS enabled before a restart")
+	}
+	if dir, ok := running.CameraHLSDir(&running.Cameras[0]); ok {
+		t.Errorf("camera writes HLS to %s before a restart", dir)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cctv-agent/internal/hls"
+)
+
+// httpShutdownTimeout bounds how long in-flight HTTP requests may take to
+// complete on shutdown
+const httpShutdownTimeout = 5 * time.Second
+
+// startHTTPServer serves the agent's HTTP endpoints on the metrics port
+func (app *Application) startHTTPServer() error {
+	cfg := app.getConfig()
+	mon := cfg.Monitoring
+	if !mon.MetricsEnabled && !mon.HealthEnabled && !cfg.HLS.Enabled {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	if mon.MetricsEnabled {
+		mux.Handle("/metrics", app.metrics.Handler())
+	}
+	if mon.HealthEnabled {
+		mux.Handle("/healthz", app.healthChecker.LivenessHandler())
+		mux.Handle("/readyz", app.healthChecker.ReadinessHandler())
+	}
+	if cfg.HLS.Enabled {
+		mux.Handle("/hls/", app.hlsServer.Handler())
+		mux.Handle("/{$}", http.RedirectHandler("/hls/", http.StatusFound))
+	}
+
+	addr := fmt.Sprintf(":%d", mon.MetricsPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	app.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := app.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Error("HTTP server failed", "error", err)
+		}
+	}()
+
+	app.logger.Info("HTTP server started", "addr", listener.Addr().String())
+	return nil
+}
+
+// hlsCameras lists the streamed cameras whose playlists the HLS server serves
+func (app *Application) hlsCameras() []hls.Camera {
+	statuses := app.streamManager.GetStatus()
+	cfg := app.getConfig()
+	cameras := make([]hls.Camera, 0, len(statuses))
+	for i := range cfg.Cameras {
+		camera := &cfg.Cameras[i]
+		status, exists := statuses[camera.ID]
+		if !exists {
+			continue
+		}
+		dir, ok := cfg.CameraHLSDir(camera)
+		if !ok {
+			continue
+		}
+		cameras = append(cameras, hls.Camera{
+			ID:     camera.ID,
+			Name:   camera.Name,
+			Status: string(status),
+			Dir:    dir,
+		})
+	}
+	return cameras
+}
+
+// stopHTTPServer gracefully stops the HTTP server
+func (app *Application) stopHTTPServer() {
+	if app.httpServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+
+	if err := app.httpServer.Shutdown(ctx); err != nil {
+		app.logger.Error("Failed to stop HTTP server", "error", err)
+	}
+}